@@ -2,34 +2,56 @@ package maya
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"regexp"
 	"strings"
-
-	"github.com/op/go-logging"
 )
 
 type Article struct {
 	MetadataText string
-	ContentText  string
-	MetadataMode string
-	loader       MetadataTemplateLoader
+	// MetadataFormat is yaml, toml or json of MetadataText. empty is yaml.
+	MetadataFormat string
+	ContentText    string
+	MetadataMode   string
+
+	// FilePath is the source file used in error messages.
+	FilePath string
+	Options
+
+	// 1-based line numbers in the original text.
+	// front-matter is stripped from ContentText.
+	metadataLine int
+	contentLine  int
+
+	loader      MetadataTemplateLoader
+	diagnostics []Diagnostic
 }
 
-func NewArticleFromReader(r io.Reader, mode string) *Article {
+func NewArticleFromReader(r io.Reader, mode string) (*Article, error) {
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(r)
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
 	text := buf.String()
 	text = strings.Replace(text, "\r", "", -1)
 	return NewArticle(text, mode)
 }
 
-func NewArticle(text string, mode string) *Article {
+// jsonMetadataRe matches the first line of JSON object,
+// not the content which starts with shortcodes like {{< youtube >}}.
+var jsonMetadataRe = regexp.MustCompile(`^\{\s*("|}|$)`)
+
+func NewArticle(text string, mode string) (*Article, error) {
 	metadataLines := []string{}
 	contentLines := []string{}
 
 	if mode == "" {
-		log := logging.MustGetLogger("maya")
-		log.Fatal("mode required. use -h")
+		return nil, errors.New("mode required")
 	}
 
 	lines := strings.Split(text, "\n")
@@ -45,6 +67,7 @@ func NewArticle(text string, mode string) *Article {
 	// metadatas
 	// ---
 	// content
+	// hugo는 +++ ~ +++ 구역을 toml로, { ~ } 구역을 json으로 파싱한다
 
 	firstLine := 0
 	for i, line := range lines {
@@ -54,16 +77,20 @@ func NewArticle(text string, mode string) *Article {
 		}
 	}
 
-	if lines[firstLine] != "---" {
-		// no metadata
-		return &Article{
-			MetadataText: "",
-			ContentText:  text,
-			MetadataMode: mode,
-			loader:       NewTemplateLoader(),
-		}
+	delimiters := map[string]string{
+		"---": MetadataYAML,
+		"+++": MetadataTOML,
+	}
+	delimiter := lines[firstLine]
+	if jsonMetadataRe.MatchString(delimiter) {
+		return newJSONArticle(text, lines, firstLine, mode)
+	}
+	format, ok := delimiters[delimiter]
+	if !ok {
+		return newContentArticle(text, mode), nil
 	}
 
+	contentLine := len(lines) + 1
 	state := LineParseStateInit
 	for i, line := range lines {
 		if i < firstLine {
@@ -71,16 +98,17 @@ func NewArticle(text string, mode string) *Article {
 		}
 		switch state {
 		case LineParseStateInit:
-			if strings.Trim(line, " ") == "---" {
+			if strings.Trim(line, " ") == delimiter {
 				state = LineParseStateMetadata
 				metadataLines = []string{}
 			} else {
 				contentLines = append(contentLines, line)
 			}
 		case LineParseStateMetadata:
-			if strings.Trim(line, " ") == "---" {
+			if strings.Trim(line, " ") == delimiter {
 				state = LineParseStateContent
 				contentLines = []string{}
+				contentLine = i + 2
 			} else {
 				metadataLines = append(metadataLines, line)
 			}
@@ -90,35 +118,209 @@ func NewArticle(text string, mode string) *Article {
 	}
 
 	return &Article{
-		MetadataText: strings.Join(metadataLines, "\n"),
-		ContentText:  strings.Join(contentLines, "\n"),
+		MetadataText:   strings.Join(metadataLines, "\n"),
+		MetadataFormat: format,
+		ContentText:    strings.Join(contentLines, "\n"),
+		MetadataMode:   mode,
+		metadataLine:   firstLine + 1,
+		contentLine:    contentLine,
+		loader:         NewTemplateLoader(),
+	}, nil
+}
+
+// newJSONArticle splits JSON object which starts at firstLine from content.
+// content starts at the line after the end of the object.
+// newContentArticle returns article without metadata.
+func newContentArticle(text string, mode string) *Article {
+	return &Article{
+		MetadataText: "",
+		ContentText:  text,
 		MetadataMode: mode,
+		contentLine:  1,
 		loader:       NewTemplateLoader(),
 	}
 }
 
-func (a *Article) Metadata() *ArticleMetadata {
-	return NewMetadata(a.MetadataText)
+// newJSONArticle reads JSON object at firstLine as metadata.
+// text which is not JSON is content like templates starting with {.
+func newJSONArticle(text string, lines []string, firstLine int, mode string) (*Article, error) {
+	offset := 0
+	for _, line := range lines[:firstLine] {
+		offset += len(line) + 1
+	}
+	r := strings.NewReader(text[offset:])
+	dec := json.NewDecoder(r)
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return newContentArticle(text, mode), nil
+	}
+	buffered, err := ioutil.ReadAll(dec.Buffered())
+	if err != nil {
+		return nil, err
+	}
+	end := len(text) - r.Len() - len(buffered)
+
+	rest := text[end:]
+	i := strings.Index(rest, "\n")
+	if i < 0 {
+		i = len(rest)
+	}
+	if trailing := strings.TrimSpace(rest[:i]); trailing != "" {
+		return nil, fmt.Errorf("json metadata: unexpected text after metadata: %q", trailing)
+	}
+
+	content := ""
+	contentLine := strings.Count(text[:end], "\n") + 2
+	if i < len(rest) {
+		content = rest[i+1:]
+	} else {
+		contentLine = len(lines) + 1
+	}
+
+	return &Article{
+		MetadataText:   text[offset:end],
+		MetadataFormat: MetadataJSON,
+		ContentText:    content,
+		MetadataMode:   mode,
+		metadataLine:   firstLine + 1,
+		contentLine:    contentLine,
+		loader:         NewTemplateLoader(),
+	}, nil
+}
+
+func (a *Article) Metadata() (*ArticleMetadata, error) {
+	return ParseMetadata(a.MetadataText, a.MetadataFormat)
 }
 
 func (a *Article) Content() *ArticleContent {
-	return NewContent(a.ContentText)
+	firstLine := a.contentLine
+	if firstLine == 0 {
+		firstLine = 1
+	}
+	content := newContent(a.ContentText, firstLine)
+	content.File = a.FilePath
+	content.Mode = a.MetadataMode
+	content.Options = a.Options
+	return content
 }
 
-func (a *Article) Output(w io.Writer) {
-	output := a.OutputString()
-	w.Write([]byte(output))
+func (a *Article) Output(w io.Writer) error {
+	output, err := a.OutputString()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(output))
+	return err
 }
 
-func (a *Article) OutputString() string {
-	metadata := a.Metadata()
-	header := a.loader.Execute(metadata, a.MetadataMode)
+func (a *Article) OutputString() (string, error) {
+	return a.OutputStringContext(context.Background())
+}
+
+// OutputStringContext is like OutputString,
+// but commands are canceled when ctx is done.
+func (a *Article) OutputStringContext(ctx context.Context) (string, error) {
+	errs := ErrorList{}
+	a.diagnostics = []Diagnostic{}
+
+	header, err := a.header()
+	if err != nil {
+		a.diagnostics = append(a.diagnostics, Diagnostic{
+			Severity: SeverityError,
+			File:     a.FilePath,
+			Start:    Position{a.metadataLine, 1},
+			End:      Position{a.contentLine, 1},
+			Message:  err.Error(),
+		})
+		err = &Error{File: a.FilePath, Line: a.metadataLine, Err: err}
+		if !a.CollectErrors {
+			return "", err
+		}
+		errs = append(errs, err)
+	}
 
 	content := a.Content()
-	body := content.String()
+	body, err := content.StringContext(ctx)
+	a.diagnostics = append(a.diagnostics, content.Diagnostics()...)
+	if err != nil {
+		if !a.CollectErrors {
+			return "", err
+		}
+		if list, ok := err.(ErrorList); ok {
+			errs = append(errs, list...)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		return "", err
+	}
 
 	output := strings.Join([]string{header, "", body}, "\n")
 	output = strings.TrimLeft(output, "\n")
 
-	return output
+	return output, nil
+}
+
+// SetTemplateLoader replaces the metadata template loader.
+// Use it to render with templates registered by RegisterFile.
+func (a *Article) SetTemplateLoader(loader MetadataTemplateLoader) {
+	a.loader = loader
+}
+
+// Dependencies returns files referenced by blocks, for example maya:view.
+// Commands are created but not executed.
+func (a *Article) Dependencies() []string {
+	found := []string{}
+	visited := map[string]bool{}
+	for _, cmd := range a.Content().commands() {
+		d, ok := cmd.(dependent)
+		if !ok {
+			continue
+		}
+		for _, dep := range d.dependencies() {
+			if !visited[dep] {
+				visited[dep] = true
+				found = append(found, dep)
+			}
+		}
+	}
+	return found
+}
+
+// CacheKeys returns cache keys and refs of blocks which store output in cache.
+// Commands are created but not executed.
+func (a *Article) CacheKeys() ([]string, error) {
+	keys := []string{}
+	for _, cmd := range a.Content().commands() {
+		c, ok := cmd.(cacher)
+		if !ok {
+			continue
+		}
+		key, ref, err := c.cacheKey()
+		if err != nil {
+			return nil, err
+		}
+		if key != "" {
+			keys = append(keys, key)
+		}
+		if ref != "" && ref != key {
+			keys = append(keys, ref)
+		}
+	}
+	return keys, nil
+}
+
+// Diagnostics returns problems found by the last OutputString.
+func (a *Article) Diagnostics() []Diagnostic {
+	return a.diagnostics
+}
+
+func (a *Article) header() (string, error) {
+	metadata, err := a.Metadata()
+	if err != nil {
+		return "", fmt.Errorf("metadata: %v", err)
+	}
+	return a.loader.Execute(metadata, a.MetadataMode)
 }
@@ -1,6 +1,8 @@
 package maya
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -56,8 +58,217 @@ func TestNewArticle(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		article := NewArticle(c.text, ModePelican)
+		article, err := NewArticle(c.text, ModePelican)
+		assert.Nil(t, err)
 		assert.Equal(t, c.metadataText, article.MetadataText)
 		assert.Equal(t, c.contentText, article.ContentText)
 	}
 }
+
+func TestNewArticle_format(t *testing.T) {
+	cases := []struct {
+		text         string
+		format       string
+		metadataText string
+		contentText  string
+		contentLine  int
+	}{
+		{
+			"---\ntitle: hello\n---\ncontent",
+			MetadataYAML, "title: hello", "content", 4,
+		},
+		{
+			"\n+++\ntitle = \"hello\"\n+++\ncontent",
+			MetadataTOML, "title = \"hello\"", "content", 5,
+		},
+		{
+			"{\n  \"title\": \"}\\\"\"\n}\ncontent\n{}",
+			MetadataJSON, "{\n  \"title\": \"}\\\"\"\n}", "content\n{}", 4,
+		},
+		{
+			"{\"title\": \"hello\"}",
+			MetadataJSON, "{\"title\": \"hello\"}", "", 2,
+		},
+		{
+			"{\"title\": \"hello\"} \t\ncontent",
+			MetadataJSON, "{\"title\": \"hello\"}", "content", 2,
+		},
+		{
+			"{{< youtube ESCv5qDuQIA >}}",
+			"", "", "{{< youtube ESCv5qDuQIA >}}", 1,
+		},
+		// not JSON is content
+		{
+			"{\"title\": \n",
+			"", "", "{\"title\": \n", 1,
+		},
+		{
+			"{\n  not json\n}\ncontent",
+			"", "", "{\n  not json\n}\ncontent", 1,
+		},
+	}
+	for _, c := range cases {
+		article, err := NewArticle(c.text, ModeEmpty)
+		if assert.Nil(t, err, c.text) {
+			assert.Equal(t, c.format, article.MetadataFormat, c.text)
+			assert.Equal(t, c.metadataText, article.MetadataText, c.text)
+			assert.Equal(t, c.contentText, article.ContentText, c.text)
+			assert.Equal(t, c.contentLine, article.contentLine, c.text)
+		}
+	}
+
+	// text after metadata in the same line is not dropped
+	_, err := NewArticle("{\"title\": \"hello\"} content\nmore", ModeEmpty)
+	assert.NotNil(t, err)
+	_, err = NewArticle("{\"title\": \"hello\"} {}", ModeEmpty)
+	assert.NotNil(t, err)
+}
+
+func TestArticle_OutputString_convertFormat(t *testing.T) {
+	cases := []struct {
+		text     string
+		mode     string
+		expected string
+	}{
+		{
+			"+++\ntitle = \"hello\"\ndate = 2016-02-20\ntags = [\"a\", \"b\"]\n+++\ncontent",
+			ModeJekyll,
+			"---\ntitle: hello\ndate: 2016-02-20\ntags:\n- a\n- b\n---\n\ncontent",
+		},
+		{
+			"{\"title\": \"hello\", \"weight\": 2, \"draft\": false}\ncontent",
+			ModeHugo,
+			"+++\ntitle = \"hello\"\nweight = 2\ndraft = false\n+++\n\ncontent",
+		},
+		{
+			"---\ntitle: hello\nweight: 2\n---\ncontent",
+			ModeJSON,
+			"{\n  \"title\": \"hello\",\n  \"weight\": 2\n}\n\ncontent",
+		},
+	}
+	for _, c := range cases {
+		article, err := NewArticle(c.text, c.mode)
+		assert.Nil(t, err)
+		actual, err := article.OutputString()
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, actual)
+	}
+}
+
+func TestNewArticle_emptyMode(t *testing.T) {
+	_, err := NewArticle("hello", "")
+	assert.NotNil(t, err)
+}
+
+func TestArticle_OutputString_error(t *testing.T) {
+	text := strings.Join([]string{
+		"---",
+		"title: hello",
+		"---",
+		"hello",
+		"~~~maya:view",
+		"file=not-exist.txt",
+		"~~~",
+		"~~~maya:view",
+		"file=cmd_test.go",
+		"format=invalid",
+		"~~~",
+	}, "\n")
+
+	article, _ := NewArticle(text, ModePelican)
+	article.FilePath = "sample.md"
+	_, err := article.OutputString()
+	if assert.IsType(t, &Error{}, err) {
+		e := err.(*Error)
+		assert.Equal(t, "sample.md", e.File)
+		assert.Equal(t, 5, e.Line)
+		assert.Equal(t, 1, e.Column)
+		assert.Equal(t, 1, e.Block)
+		assert.Equal(t, "view", e.Action)
+	}
+
+	article.CollectErrors = true
+	_, err = article.OutputString()
+	if assert.IsType(t, ErrorList{}, err) {
+		list := err.(ErrorList)
+		assert.Len(t, list, 2)
+		assert.Equal(t, 2, list[1].(*Error).Block)
+		assert.Equal(t, 8, list[1].(*Error).Line)
+	}
+
+	diagnostics := article.Diagnostics()
+	assert.Len(t, diagnostics, 2)
+	assert.Equal(t, SeverityError, diagnostics[0].Severity)
+	assert.Equal(t, Position{5, 1}, diagnostics[0].Start)
+	assert.Equal(t, Position{7, 4}, diagnostics[0].End)
+}
+
+func TestArticle_OutputString_invalidMetadata(t *testing.T) {
+	cases := []struct {
+		text string
+		mode string
+	}{
+		{"---\ntitle: [hello\n---\n", ModePelican},
+		{"---\ntitle: hello\n---\n", "invalid-mode"},
+	}
+	for _, c := range cases {
+		article, _ := NewArticle(c.text, c.mode)
+		_, err := article.OutputString()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestArticle_Dependencies(t *testing.T) {
+	text := strings.Join([]string{
+		"~~~maya:view",
+		"file=demo.py",
+		"~~~",
+		"~~~maya:execute",
+		"cmd=python demo.py",
+		"~~~",
+		"~~~maya:view",
+		"file=demo.sh",
+		"~~~",
+		"~~~maya:view",
+		"file=demo.py",
+		"~~~",
+	}, "\n")
+	article, _ := NewArticle(text, ModeEmpty)
+	cwd, _ := os.Getwd()
+	assert.Equal(t, []string{
+		filepath.Join(cwd, "demo.py"),
+		filepath.Join(cwd, "demo.sh"),
+	}, article.Dependencies())
+
+	// relative to the document
+	article.FilePath = filepath.Join("document", "article.md")
+	assert.Equal(t, []string{
+		filepath.Join(cwd, "document", "demo.py"),
+		filepath.Join(cwd, "document", "demo.sh"),
+	}, article.Dependencies())
+}
+
+func TestArticle_CacheKeys(t *testing.T) {
+	text := strings.Join([]string{
+		"~~~maya:execute",
+		"cmd=echo foo",
+		"~~~",
+		"~~~maya:execute",
+		"cmd=echo bar",
+		"cache=false",
+		"~~~",
+		"~~~maya:view",
+		"file=demo.py",
+		"~~~",
+	}, "\n")
+	article, _ := NewArticle(text, ModeEmpty)
+	keys, err := article.CacheKeys()
+	assert.Nil(t, err)
+	// key and ref of the block
+	assert.Len(t, keys, 2)
+
+	article.NoCache = true
+	keys, err = article.CacheKeys()
+	assert.Nil(t, err)
+	assert.Len(t, keys, 0)
+}
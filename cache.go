@@ -0,0 +1,171 @@
+package maya
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const cacheExt = ".json"
+
+// cache files of older versions are named by md5 and never read.
+var legacyCacheRe = regexp.MustCompile(`^[0-9a-f]{32}\.txt$`)
+
+// temp files of store are left behind if the process dies before rename.
+// PruneCache removes them after the grace period, which no store takes.
+const (
+	cacheTmpPrefix = ".tmp-"
+	cacheTmpGrace  = time.Hour
+)
+
+// fileCache stores command output as json files.
+type fileCache struct {
+	dir string
+}
+
+type cacheEntry struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Ref       string          `json:"ref,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// cacher is implemented by commands which store output in cache.
+// cacheKey returns empty key if cache is not used.
+// ref identifies the block without the environment of the process,
+// PruneCache keeps entries by it.
+type cacher interface {
+	cacheKey() (key, ref string, err error)
+}
+
+// newFileCache uses ./cache if dir is empty.
+func newFileCache(dir string) *fileCache {
+	if dir == "" {
+		// 실행 경로를 캐시 생성 경로로 이용
+		pwd, _ := os.Getwd()
+		dir = filepath.Join(pwd, "cache")
+	}
+	return &fileCache{dir}
+}
+
+func (c *fileCache) path(key string) string {
+	return filepath.Join(c.dir, key+cacheExt)
+}
+
+// load returns false if the entry does not exist or is older than ttl.
+// zero ttl means forever.
+func (c *fileCache) load(key string, ttl time.Duration, v interface{}) bool {
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return false
+	}
+	entry := cacheEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return false
+	}
+	if ttl > 0 && time.Since(entry.CreatedAt) > ttl {
+		return false
+	}
+	return json.Unmarshal(entry.Data, v) == nil
+}
+
+// store writes to temp file and renames it,
+// blocks running concurrently should not see half written file.
+func (c *fileCache) store(key, ref string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	entry := cacheEntry{
+		CreatedAt: time.Now(),
+		Ref:       ref,
+		Data:      data,
+	}
+	text, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	tmpfile, err := ioutil.TempFile(c.dir, cacheTmpPrefix)
+	if err != nil {
+		return err
+	}
+	if _, err := tmpfile.Write(text); err != nil {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	if err := tmpfile.Close(); err != nil {
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	if err := os.Rename(tmpfile.Name(), c.path(key)); err != nil {
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	return nil
+}
+
+// ref returns ref of the entry, empty if it is not readable.
+func (c *fileCache) ref(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	entry := cacheEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return ""
+	}
+	return entry.Ref
+}
+
+// PruneCache removes cache entries in dir except those whose key or ref is in keep,
+// temp files of interrupted writes older than an hour and cache files of older versions.
+// It returns removed file paths.
+func PruneCache(dir string, keep []string) ([]string, error) {
+	c := newFileCache(dir)
+	keepSet := map[string]bool{}
+	for _, key := range keep {
+		keepSet[key] = true
+	}
+
+	infos, err := ioutil.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			continue
+		}
+		path := filepath.Join(c.dir, name)
+		switch {
+		case strings.HasPrefix(name, cacheTmpPrefix):
+			if time.Since(info.ModTime()) < cacheTmpGrace {
+				continue
+			}
+		case strings.HasSuffix(name, cacheExt):
+			if keepSet[strings.TrimSuffix(name, cacheExt)] || keepSet[c.ref(path)] {
+				continue
+			}
+		case !legacyCacheRe.MatchString(name):
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
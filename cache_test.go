@@ -0,0 +1,81 @@
+package maya
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCache(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+
+	type value struct {
+		Text string
+	}
+
+	cache := newFileCache(dir)
+	assert.Nil(t, cache.store("foo", "", value{"hello"}))
+
+	v := value{}
+	assert.True(t, cache.load("foo", 0, &v))
+	assert.Equal(t, "hello", v.Text)
+
+	assert.True(t, cache.load("foo", time.Hour, &v))
+	time.Sleep(10 * time.Millisecond)
+	assert.False(t, cache.load("foo", time.Millisecond, &v))
+	assert.False(t, cache.load("bar", 0, &v))
+
+	ioutil.WriteFile(cache.path("invalid"), []byte("invalid"), 0644)
+	assert.False(t, cache.load("invalid", 0, &v))
+}
+
+func TestPruneCache(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+
+	cache := newFileCache(dir)
+	for _, key := range []string{"a", "b", "c"} {
+		cache.store(key, "ref-"+key, key)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "README"), []byte(""), 0644)
+	// cache files of older versions
+	legacy := filepath.Join(dir, "d41d8cd98f00b204e9800998ecf8427e.txt")
+	ioutil.WriteFile(legacy, []byte(""), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte(""), 0644)
+
+	// entries are kept by key or by ref
+	removed, err := PruneCache(dir, []string{"b", "ref-c"})
+	assert.Nil(t, err)
+	sort.Strings(removed)
+	assert.Equal(t, []string{cache.path("a"), legacy}, removed)
+
+	infos, _ := ioutil.ReadDir(dir)
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	assert.Equal(t, []string{"README", "b.json", "c.json", "notes.txt"}, names)
+
+	// temp files of interrupted writes are removed after the grace period
+	stale := filepath.Join(dir, cacheTmpPrefix+"1")
+	fresh := filepath.Join(dir, cacheTmpPrefix+"2")
+	ioutil.WriteFile(stale, []byte("{"), 0644)
+	ioutil.WriteFile(fresh, []byte("{"), 0644)
+	old := time.Now().Add(-cacheTmpGrace - time.Minute)
+	os.Chtimes(stale, old, old)
+	removed, err = PruneCache(dir, []string{"b", "ref-c"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{stale}, removed)
+	_, err = os.Stat(fresh)
+	assert.Nil(t, err)
+
+	removed, err = PruneCache(filepath.Join(dir, "not-exist"), nil)
+	assert.Nil(t, err)
+	assert.Empty(t, removed)
+}
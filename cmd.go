@@ -1,18 +1,115 @@
 package maya
 
 import (
+	"context"
+	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-type cmd interface {
-	output() []string
-	execute() string
+// Command is a maya:<action> block.
+// Execute returns the markdown text which replaces the block.
+// Long running commands should stop when ctx is done.
+type Command interface {
+	Execute(ctx context.Context) (string, error)
+}
+
+// CommandFactory creates a new command.
+// It should return a pointer to struct. Fields tagged with
+// `maya:"key,default"` are filled from the block parameters.
+type CommandFactory func() Command
+
+type createCmdFunc func(*cmdArgs) Command
+
+// dependent is implemented by commands which read files.
+type dependent interface {
+	dependencies() []string
+}
+
+type cmdRegistry struct {
+	mutex sync.RWMutex
+	table map[string]createCmdFunc
+}
+
+var actionRe = regexp.MustCompile(`^\w+$`)
+
+var registry = &cmdRegistry{
+	table: map[string]createCmdFunc{
+		"view":    newCmdView,
+		"execute": newCmdExecute,
+		"youtube": newCmdYoutube,
+		"gist":    newCmdGist,
+		"diff":    newCmdDiff,
+		"embed":   newCmdEmbed,
+	},
+}
+
+// RegisterCommand registers a command used by ~~~maya:<name> blocks.
+// It is not allowed to register the same name twice.
+func RegisterCommand(name string, factory CommandFactory) error {
+	if !actionRe.MatchString(name) {
+		return fmt.Errorf("invalid command name: %q", name)
+	}
+	if factory == nil {
+		return fmt.Errorf("nil factory: %s", name)
+	}
+	v := reflect.ValueOf(factory())
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("factory should return pointer to struct: %s", name)
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if _, ok := registry.table[name]; ok {
+		return fmt.Errorf("command already registered: %s", name)
+	}
+	registry.table[name] = func(args *cmdArgs) Command {
+		return fillCmd(factory(), args)
+	}
+	return nil
+}
+
+// RegisteredCommands returns sorted names of registered commands.
+func RegisteredCommands() []string {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	names := []string{}
+	for name := range registry.table {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 type cmdArgs struct {
 	params map[string]string
+	// lists has every value of repeated keys in order.
+	lists map[string][]string
+	env   *environment
+}
+
+// environment returns empty environment if it is not set.
+func (args *cmdArgs) environment() *environment {
+	if args.env == nil {
+		return &environment{}
+	}
+	return args.env
+}
+
+// listVal returns every value of key. it is nil if key does not exist.
+func (args *cmdArgs) listVal(key string) []string {
+	if vals, ok := args.lists[key]; ok {
+		return vals
+	}
+	if val, ok := args.params[key]; ok {
+		return []string{val}
+	}
+	return nil
 }
 
 func (args *cmdArgs) intVal(key string, defaultVal int) int {
@@ -30,6 +127,21 @@ func (args *cmdArgs) stringVal(key string, defaultVal string) string {
 	return defaultVal
 }
 
+// durationVal accepts duration string like "1m30s" or seconds.
+func (args *cmdArgs) durationVal(key string, defaultVal time.Duration) time.Duration {
+	val, ok := args.params[key]
+	if !ok {
+		return defaultVal
+	}
+	if sec, err := strconv.Atoi(val); err == nil {
+		return time.Duration(sec) * time.Second
+	}
+	if d, err := time.ParseDuration(val); err == nil {
+		return d
+	}
+	return defaultVal
+}
+
 func (args *cmdArgs) boolVal(key string, defaultVal bool) bool {
 	trueStr := []string{
 		"true",
@@ -57,25 +169,25 @@ func (args *cmdArgs) boolVal(key string, defaultVal bool) bool {
 	return defaultVal
 }
 
-func newCmd(action string, args *cmdArgs) cmd {
-	type CreateFunc func(*cmdArgs) cmd
-	table := map[string]CreateFunc{
-		"view":    newCmdView,
-		"execute": newCmdExecute,
-		"youtube": newCmdYoutube,
-		"gist":    newCmdGist,
-	}
-	if fn, ok := table[action]; ok {
+func newCmd(action string, args *cmdArgs) Command {
+	registry.mutex.RLock()
+	fn, ok := registry.table[action]
+	registry.mutex.RUnlock()
+	if ok {
 		return fn(args)
 	}
 	return newCmdUnknown(action, args)
 }
 
-func fillCmd(c cmd, args *cmdArgs) cmd {
+func fillCmd(c Command, args *cmdArgs) Command {
 	t := reflect.TypeOf(c).Elem()
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
 		tag := field.Tag.Get("maya")
+		if tag == "" || field.PkgPath != "" {
+			// not a parameter or unexported
+			continue
+		}
 		tokens := strings.Split(tag, ",")
 
 		keyIdx := 0
@@ -103,6 +215,15 @@ func fillCmd(c cmd, args *cmdArgs) cmd {
 			reflect.ValueOf(c).Elem().Field(i).SetInt(int64(v))
 			break
 
+		case reflect.TypeOf(time.Duration(0)):
+			var defaultVal time.Duration
+			if len(tokens) >= defaultValIdx+1 {
+				defaultVal, _ = time.ParseDuration(tokens[defaultValIdx])
+			}
+			v := args.durationVal(key, defaultVal)
+			reflect.ValueOf(c).Elem().Field(i).SetInt(int64(v))
+			break
+
 		case reflect.TypeOf(true):
 			defaultVal := false
 			if len(tokens) >= defaultValIdx+1 {
@@ -116,6 +237,12 @@ func fillCmd(c cmd, args *cmdArgs) cmd {
 			v := args.boolVal(key, defaultVal)
 			reflect.ValueOf(c).Elem().Field(i).SetBool(v)
 			break
+
+		case reflect.TypeOf([]string{}):
+			// repeated keys, for example env=A=1 and env=B=2
+			v := args.listVal(key)
+			reflect.ValueOf(c).Elem().Field(i).Set(reflect.ValueOf(v))
+			break
 		}
 	}
 	return c
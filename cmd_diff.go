@@ -0,0 +1,48 @@
+package maya
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// cmdDiff renders unified diff of a file between git revisions.
+type cmdDiff struct {
+	FilePath string `maya:"file"`
+	From     string `maya:"from"`
+	// To is compared with From. if empty, the working tree is used.
+	To      string `maya:"to"`
+	Repo    string `maya:"repo"`
+	Context int    `maya:"context,3"`
+
+	env environment
+}
+
+func newCmdDiff(args *cmdArgs) Command {
+	c := &cmdDiff{}
+	fillCmd(c, args)
+	c.env = *args.environment()
+	return c
+}
+
+func (c *cmdDiff) Execute(ctx context.Context) (string, error) {
+	if c.FilePath == "" {
+		return "", fmt.Errorf("file required")
+	}
+	if c.From == "" {
+		return "", fmt.Errorf("from required")
+	}
+	if c.Context < 0 {
+		return "", fmt.Errorf("invalid context: %d", c.Context)
+	}
+	path, err := c.env.repoPath(c.Repo, c.FilePath)
+	if err != nil {
+		return "", err
+	}
+	diff, err := gitDiff(ctx, path, c.From, c.To, c.Context)
+	if err != nil {
+		return "", err
+	}
+	f := &codeFormatter{}
+	return f.format(strings.Split(diff, "\n"), "diff"), nil
+}
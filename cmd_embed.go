@@ -0,0 +1,64 @@
+package maya
+
+import (
+	"context"
+	"fmt"
+)
+
+// cmdEmbed renders content of the provider which matches url.
+type cmdEmbed struct {
+	URL string `maya:"url"`
+
+	params map[string]string
+	env    environment
+}
+
+// embedBoolParams are boolean parameters which templates test with if.
+// they are "1" if true and removed if false.
+var embedBoolParams = []string{"autoplay", "nocookie"}
+
+func newCmdEmbed(args *cmdArgs) Command {
+	c := &cmdEmbed{}
+	fillCmd(c, args)
+	c.params = map[string]string{}
+	for k, v := range args.params {
+		c.params[k] = v
+	}
+	for _, key := range embedBoolParams {
+		val, ok := c.params[key]
+		if !ok {
+			continue
+		}
+		if args.boolVal(key, val == "1") {
+			c.params[key] = "1"
+		} else {
+			delete(c.params, key)
+		}
+	}
+	c.env = *args.environment()
+	return c
+}
+
+// renderEmbed renders url with the provider which matches it.
+// output is native to the mode of env, html if the provider has no template for it.
+func renderEmbed(env *environment, url string, params map[string]string) (string, error) {
+	p, groups := embeds.match(url)
+	if p == nil {
+		return "", fmt.Errorf("no embed provider matches %s", url)
+	}
+	data := embedData{
+		URL:    url,
+		ID:     groups["id"],
+		User:   groups["user"],
+		Slug:   groups["slug"],
+		Params: params,
+	}
+	return p.render(env.mode, data)
+}
+
+func (c *cmdEmbed) Execute(ctx context.Context) (string, error) {
+	if c.URL == "" {
+		return "", fmt.Errorf("url required")
+	}
+	return renderEmbed(&c.env, c.URL, c.params)
+}
@@ -1,150 +1,419 @@
 package maya
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/op/go-logging"
 )
 
-type cmdExecute struct {
-	Cmd       string `maya:"cmd,echo empty"`
-	AttachCmd bool   `maya:"attach_cmd,false"`
-	Format    string `maya:"format,code"`
+// shells maps shell parameter to interpreter which runs a script file.
+var shells = map[string]string{
+	"sh":     "sh",
+	"bash":   "bash",
+	"zsh":    "zsh",
+	"python": "python3",
 }
 
-func newCmdExecute(args *cmdArgs) cmd {
-	return fillCmd(&cmdExecute{}, args)
+const (
+	stderrMerge      = "merge"
+	stderrCode       = "code"
+	stderrBlockquote = "blockquote"
+	stderrHide       = "hide"
+)
+
+type cmdExecute struct {
+	Cmd       string        `maya:"cmd,echo empty"`
+	AttachCmd bool          `maya:"attach_cmd,false"`
+	Format    string        `maya:"format,code"`
+	Timeout   time.Duration `maya:"timeout"`
+
+	// Cwd is the working directory. relative path is resolved from the document.
+	Cwd string `maya:"cwd"`
+	// Env is list of KEY=VAL added to the process environment.
+	Env []string `maya:"env"`
+	// Shell runs cmd as a script. sh/bash/zsh/python
+	Shell string `maya:"shell"`
+	// Stdin is lines written to stdin. StdinFile is resolved from Cwd.
+	Stdin     []string `maya:"stdin"`
+	StdinFile string   `maya:"stdin_file"`
+
+	// Stderr is how stderr is rendered. merge/code/blockquote/hide
+	Stderr     string `maya:"stderr,merge"`
+	ShowExit   bool   `maya:"show_exit,false"`
+	ExpectExit int    `maya:"expect_exit,-1"`
+
+	Cache bool          `maya:"cache,true"`
+	TTL   time.Duration `maya:"ttl"`
+	// Depends is comma separated globs of input files.
+	Depends string `maya:"depends"`
+	// DependsEnv is comma separated names of environment variables.
+	DependsEnv string `maya:"depends_env"`
+
+	env environment
 }
 
-func (c *cmdExecute) cacheFileName() string {
-	data := []byte(c.Cmd)
-	return fmt.Sprintf("%x.txt", md5.Sum(data))
+// executeResult is output of a command. it is stored in cache.
+// Combined is set when stderr is merged, Stdout and Stderr otherwise.
+type executeResult struct {
+	Cmd      string `json:"cmd"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	Combined string `json:"combined"`
+	ExitCode int    `json:"exit_code"`
 }
 
-func (c *cmdExecute) cacheDir() string {
-	// 실행 경로를 캐시 생성 경로로 이용
-	pwd, _ := os.Getwd()
-	cachePath := filepath.Join(pwd, "cache")
-	return cachePath
+func newCmdExecute(args *cmdArgs) Command {
+	c := &cmdExecute{}
+	fillCmd(c, args)
+	c.env = *args.environment()
+	if c.Timeout == 0 {
+		c.Timeout = c.env.Timeout
+	}
+	return c
 }
 
-func (c *cmdExecute) cacheFilePath() string {
-	dir := c.cacheDir()
-	filename := c.cacheFileName()
-	os.MkdirAll(dir, 0755)
-	return filepath.Join(dir, filename)
+// cacheKey is hash of everything which can change the output.
+// ref leaves out values of depends_env and absolute paths, which depend on the process.
+func (c *cmdExecute) cacheKey() (string, string, error) {
+	if !c.useCache() {
+		return "", "", nil
+	}
+
+	key := md5.New()
+	ref := md5.New()
+	both := io.MultiWriter(key, ref)
+	write := func(h io.Writer, name, val string) {
+		fmt.Fprintf(h, "%s=%q\n", name, val)
+	}
+
+	write(both, "cmd", c.Cmd)
+	write(key, "cwd", c.workDir())
+	write(ref, "cwd", c.Cwd)
+	write(both, "shell", c.Shell)
+	// merged output has no separate stdout and stderr
+	write(both, "merge", fmt.Sprint(c.mergeStderr()))
+	for _, kv := range c.Env {
+		write(both, "env", kv)
+	}
+	for _, name := range splitList(c.DependsEnv) {
+		write(key, "env:"+name, os.Getenv(name))
+		write(ref, "env:"+name, "")
+	}
+
+	stdin, err := c.stdin()
+	if err != nil {
+		return "", "", err
+	}
+	write(both, "stdin", fmt.Sprintf("%x", md5.Sum(stdin)))
+
+	files, err := c.dependFiles()
+	if err != nil {
+		return "", "", err
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", "", err
+		}
+		sum := fmt.Sprintf("%x", md5.Sum(data))
+		write(key, "file:"+file, sum)
+		rel, err := filepath.Rel(c.workDir(), file)
+		if err != nil {
+			rel = file
+		}
+		write(ref, "file:"+filepath.ToSlash(rel), sum)
+	}
+	return fmt.Sprintf("%x", key.Sum(nil)), fmt.Sprintf("%x", ref.Sum(nil)), nil
 }
 
-func (c *cmdExecute) cacheExists() bool {
-	_, err := os.Stat(c.cacheFilePath())
-	return !os.IsNotExist(err)
+// workDir returns absolute working directory of the command.
+// default is the directory of the document, or the process cwd if unknown.
+func (c *cmdExecute) workDir() string {
+	dir := c.Cwd
+	if dir == "" {
+		return c.env.dir()
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(c.env.dir(), dir)
+	}
+	return filepath.Clean(dir)
 }
 
-func (c *cmdExecute) readCache() []string {
-	data, _ := ioutil.ReadFile(c.cacheFilePath())
-	text := string(data[:])
-	lines := strings.Split(text, "\n")
+// resolve makes path relative to the working directory absolute.
+func (c *cmdExecute) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.workDir(), path)
+}
 
-	retval := []string{}
-	for _, line := range lines {
-		if !strings.HasPrefix(line, "# ") {
-			retval = append(retval, line)
+// stdin returns data written to stdin of the process. nil if nothing.
+func (c *cmdExecute) stdin() ([]byte, error) {
+	if c.StdinFile != "" {
+		if len(c.Stdin) > 0 {
+			return nil, fmt.Errorf("stdin and stdin_file cannot be used together")
 		}
+		return ioutil.ReadFile(c.resolve(c.StdinFile))
 	}
-	return retval
+	if len(c.Stdin) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(c.Stdin, "\n") + "\n"), nil
 }
 
-func (c *cmdExecute) writeCache(lines []string) bool {
-	cacheLines := []string{
-		"# " + c.Cmd,
+// dependFiles expands globs of depends in stable order.
+// globs are resolved from the working directory.
+func (c *cmdExecute) dependFiles() ([]string, error) {
+	files := []string{}
+	for _, pattern := range splitList(c.Depends) {
+		matches, err := filepath.Glob(c.resolve(pattern))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("depends: no file matches %s", pattern)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
 	}
-	cacheLines = append(cacheLines, lines...)
-	data := []byte(strings.Join(cacheLines, "\n"))
-	ioutil.WriteFile(c.cacheFilePath(), data, 0644)
-	return true
+	return files, nil
 }
 
-func (c *cmdExecute) output() []string {
-	outputLines := []string{}
-	if c.cacheExists() {
-		outputLines = c.readCache()
-	} else {
-		outputLines = c.ExecuteImmediately()
-		c.writeCache(outputLines)
+// mergeStderr reports whether stderr is written in the same output as stdout.
+func (c *cmdExecute) mergeStderr() bool {
+	return c.Stderr == stderrMerge || c.Stderr == ""
+}
+
+func (c *cmdExecute) useCache() bool {
+	return c.Cache && !c.env.NoCache
+}
+
+func (c *cmdExecute) result(ctx context.Context) (*executeResult, error) {
+	log := logging.MustGetLogger("maya")
+
+	cache := newFileCache(c.env.CacheDir)
+	key, ref, err := c.cacheKey()
+	if err != nil {
+		return nil, err
 	}
+	if key != "" {
+		result := &executeResult{}
+		if !c.env.RefreshCache && cache.load(key, c.TTL, result) {
+			log.Debugf("Command execute cached: %s, %s", key, c.Cmd)
+			return result, nil
+		}
+	}
+
+	result, err := c.ExecuteImmediately(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		if err := cache.store(key, ref, result); err != nil {
+			log.Warningf("cannot write cache: %v", err)
+		}
+	}
+	return result, nil
+}
 
+func (c *cmdExecute) lines(text string, attachCmd bool) []string {
 	elems := []string{}
-	if c.AttachCmd {
+	if attachCmd {
 		elems = append(elems, "$ "+c.Cmd)
 	}
-	elems = append(elems, outputLines...)
+	elems = append(elems, strings.Split(text, "\n")...)
 	elems = sanitizeLineFeedMultiLine(elems)
 	return elems
 }
 
-func (c *cmdExecute) executeImmediatelyUnix() []string {
-	tmpfile, err := ioutil.TempFile("", "maya")
+// run runs the process and kills its process group when ctx is done.
+// child processes holding stdout would block Wait forever otherwise.
+func (c *cmdExecute) run(ctx context.Context, cmd *exec.Cmd) (*executeResult, error) {
+	stdin, err := c.stdin()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	defer os.Remove(tmpfile.Name())
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	for _, kv := range c.Env {
+		if !strings.Contains(kv, "=") {
+			return nil, fmt.Errorf("invalid env: %q, expected KEY=VAL", kv)
+		}
+	}
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	cmd.Dir = c.workDir()
 
-	if _, err := tmpfile.Write([]byte(c.Cmd)); err != nil {
-		panic(err)
+	var stdout, stderr, combined bytes.Buffer
+	if c.mergeStderr() {
+		// the same writer makes exec share one pipe, which keeps the order of writes
+		cmd.Stdout = &combined
+		cmd.Stderr = &combined
+	} else {
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
 	}
-	if err := tmpfile.Close(); err != nil {
-		panic(err)
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		if _, ok := err.(*exec.Error); ok {
+			// command not found is rendered as output
+			msg := err.Error()
+			return &executeResult{Cmd: c.Cmd, Stdout: msg, Combined: msg, ExitCode: 127}, nil
+		}
+		return nil, err
 	}
 
-	out, err := exec.Command("bash", tmpfile.Name()).CombinedOutput()
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+	err = cmd.Wait()
+	close(done)
 
-	elems := []string{}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	exitCode := 0
 	if err != nil {
-		if _, ok := err.(*exec.Error); ok {
-			elems = append(elems, err.Error())
-			return elems
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil, err
 		}
+		exitCode = exitErr.ExitCode()
 	}
 
-	elems = strings.Split(string(out[:]), "\n")
-	return elems
+	return &executeResult{
+		Cmd:      c.Cmd,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Combined: combined.String(),
+		ExitCode: exitCode,
+	}, nil
 }
 
-func (c *cmdExecute) executeImmediatelyWindows() []string {
-	// https://groups.google.com/forum/#!topic/golang-nuts/Qtaw8r3Sx68
-	out, err := exec.Command("cmd", "/c", c.Cmd).CombinedOutput()
-	elems := []string{}
+// executeScript writes cmd to a temporary file and runs it with interpreter.
+func (c *cmdExecute) executeScript(ctx context.Context, interpreter string) (*executeResult, error) {
+	tmpfile, err := ioutil.TempFile("", "maya")
 	if err != nil {
-		if _, ok := err.(*exec.Error); ok {
-			elems = append(elems, err.Error())
-			return elems
-		}
+		return nil, err
 	}
+	defer os.Remove(tmpfile.Name())
 
-	elems = strings.Split(string(out[:]), "\n")
-	return elems
+	if _, err := tmpfile.Write([]byte(c.Cmd)); err != nil {
+		tmpfile.Close()
+		return nil, err
+	}
+	if err := tmpfile.Close(); err != nil {
+		return nil, err
+	}
+
+	return c.run(ctx, exec.CommandContext(ctx, interpreter, tmpfile.Name()))
 }
 
-func (c *cmdExecute) ExecuteImmediately() []string {
+func (c *cmdExecute) executeImmediatelyWindows(ctx context.Context) (*executeResult, error) {
+	// https://groups.google.com/forum/#!topic/golang-nuts/Qtaw8r3Sx68
+	return c.run(ctx, exec.CommandContext(ctx, "cmd", "/c", c.Cmd))
+}
+
+func (c *cmdExecute) ExecuteImmediately(ctx context.Context) (*executeResult, error) {
 	log := logging.MustGetLogger("maya")
 	log.Infof("Command execute: %v", c)
 
-	switch runtime.GOOS {
-	case "windows":
-		return c.executeImmediatelyWindows()
-	default:
-		return c.executeImmediatelyUnix()
+	parent := ctx
+	start := time.Now()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
 	}
+
+	result, err := c.execute(ctx)
+	if err == context.DeadlineExceeded {
+		// deadline of the caller may come before the timeout of the command
+		if parent.Err() == context.DeadlineExceeded {
+			elapsed := time.Since(start).Round(time.Millisecond)
+			return nil, fmt.Errorf("command stopped by deadline of the caller after %v: %s", elapsed, c.Cmd)
+		}
+		return nil, fmt.Errorf("command timed out after %v: %s", c.Timeout, c.Cmd)
+	}
+	return result, err
 }
 
-func (c *cmdExecute) execute() string {
-	f := newFormatter(c.Format)
-	return f.format(c.output(), "bash")
+// execute runs cmd with the shell until ctx is done.
+func (c *cmdExecute) execute(ctx context.Context) (*executeResult, error) {
+	if c.Shell == "" {
+		switch runtime.GOOS {
+		case "windows":
+			return c.executeImmediatelyWindows(ctx)
+		default:
+			return c.executeScript(ctx, shells["bash"])
+		}
+	}
+	interpreter, ok := shells[c.Shell]
+	if !ok {
+		return nil, fmt.Errorf("unknown shell: %s", c.Shell)
+	}
+	return c.executeScript(ctx, interpreter)
+}
+
+func (c *cmdExecute) Execute(ctx context.Context) (string, error) {
+	f, err := newFormatter(c.Format)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := c.result(ctx)
+	if err != nil {
+		return "", err
+	}
+	if c.ExpectExit >= 0 && result.ExitCode != c.ExpectExit {
+		return "", fmt.Errorf("exit status %d, expected %d: %s", result.ExitCode, c.ExpectExit, c.Cmd)
+	}
+
+	isBlank := func(text string) bool {
+		return strings.TrimSpace(text) == ""
+	}
+
+	sections := []string{}
+	switch c.Stderr {
+	case stderrMerge, "":
+		sections = append(sections, f.format(c.lines(result.Combined, c.AttachCmd), "bash"))
+	case stderrHide:
+		sections = append(sections, f.format(c.lines(result.Stdout, c.AttachCmd), "bash"))
+	case stderrCode, stderrBlockquote:
+		sections = append(sections, f.format(c.lines(result.Stdout, c.AttachCmd), "bash"))
+		if !isBlank(result.Stderr) {
+			stderrFormatter, _ := newFormatter(c.Stderr)
+			lines := strings.Split(strings.TrimRight(result.Stderr, "\n"), "\n")
+			lines = sanitizeLineFeedMultiLine(lines)
+			sections = append(sections, stderrFormatter.format(lines, "bash"))
+		}
+	default:
+		return "", fmt.Errorf("unknown stderr mode: %s", c.Stderr)
+	}
+
+	if c.ShowExit {
+		bold, _ := newFormatter(formatBold)
+		sections = append(sections, bold.format([]string{fmt.Sprintf("exit status %d", result.ExitCode)}))
+	}
+	return strings.Join(sections, "\n\n"), nil
 }
@@ -0,0 +1,18 @@
+package maya
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	// kill child processes too
+	pid := strconv.Itoa(cmd.Process.Pid)
+	exec.Command("taskkill", "/T", "/F", "/PID", pid).Run()
+}
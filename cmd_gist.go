@@ -1,31 +1,110 @@
 package maya
 
 import (
+	"context"
+	"crypto/md5"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
 )
 
 type cmdGist struct {
 	ID   string `maya:"id"`
 	File string `maya:"file"`
+
+	// Inline renders the file as code block instead of script tag.
+	Inline bool          `maya:"inline,false"`
+	Lang   string        `maya:"lang"`
+	Cache  bool          `maya:"cache,true"`
+	TTL    time.Duration `maya:"ttl"`
+
+	env environment
 }
 
-func newCmdGist(args *cmdArgs) cmd {
-	return fillCmd(&cmdGist{}, args)
+func newCmdGist(args *cmdArgs) Command {
+	c := &cmdGist{}
+	fillCmd(c, args)
+	c.env = *args.environment()
+	return c
 }
 
-func (c *cmdGist) output() []string {
-	url := fmt.Sprintf("https://gist.github.com/%s.js", c.ID)
-	if c.File != "" {
-		url += fmt.Sprintf("?file=%s", c.File)
+// cacheKey of gist does not depend on the process, so ref is the same as key.
+func (c *cmdGist) cacheKey() (string, string, error) {
+	if !c.Inline || !c.Cache || c.env.NoCache {
+		return "", "", nil
 	}
-	return []string{
-		`<div class="maya-gist">`,
-		fmt.Sprintf(`<script src="%s"></script>`, url),
-		`</div>`,
+	h := md5.New()
+	fmt.Fprintf(h, "gist=%q\nfile=%q\n", c.ID, c.File)
+	key := fmt.Sprintf("%x", h.Sum(nil))
+	return key, key, nil
+}
+
+func (c *cmdGist) fetcher() GistFetcher {
+	if c.env.GistFetcher != nil {
+		return c.env.GistFetcher
 	}
+	return &HTTPGistFetcher{}
 }
 
-func (c *cmdGist) execute() string {
-	f := newFormatter(formatText)
-	return f.format(c.output())
+// gistFile fetches the file of gist. fetched file is stored in cache.
+func (c *cmdGist) gistFile(ctx context.Context) (*GistFile, error) {
+	log := logging.MustGetLogger("maya")
+
+	cache := newFileCache(c.env.CacheDir)
+	key, ref, _ := c.cacheKey()
+	if key != "" && !c.env.RefreshCache {
+		file := &GistFile{}
+		if cache.load(key, c.TTL, file) {
+			log.Debugf("Gist cached: %s, %s", key, c.ID)
+			return file, nil
+		}
+	}
+
+	gist, err := c.fetcher().FetchGist(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := gist.file(c.File)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		if err := cache.store(key, ref, file); err != nil {
+			log.Warningf("cannot write cache: %v", err)
+		}
+	}
+	return file, nil
+}
+
+func (c *cmdGist) inline(ctx context.Context) (string, error) {
+	file, err := c.gistFile(ctx)
+	if err != nil {
+		return "", err
+	}
+	lang := c.Lang
+	if lang == "" {
+		lang = strings.TrimPrefix(filepath.Ext(file.Name), ".")
+	}
+	if lang == "" {
+		lang = strings.ToLower(file.Language)
+	}
+	f := &codeFormatter{}
+	return f.format(strings.Split(file.Content, "\n"), lang), nil
+}
+
+func (c *cmdGist) Execute(ctx context.Context) (string, error) {
+	if c.ID == "" {
+		return "", fmt.Errorf("id required")
+	}
+	if c.Inline {
+		return c.inline(ctx)
+	}
+	params := map[string]string{}
+	if c.File != "" {
+		params["file"] = c.File
+	}
+	return renderEmbed(&c.env, "https://gist.github.com/"+c.ID, params)
 }
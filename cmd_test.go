@@ -1,9 +1,16 @@
 package maya
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,7 +27,7 @@ func Test_commandArgs_intVal(t *testing.T) {
 		{map[string]string{"key": "invalid"}, "key", 1, 1},
 	}
 	for _, c := range cases {
-		ca := cmdArgs{c.params}
+		ca := cmdArgs{params: c.params}
 		assert.Equal(t, c.expected, ca.intVal(c.key, c.defaultVal))
 	}
 }
@@ -36,11 +43,43 @@ func Test_commandArgs_stringVal(t *testing.T) {
 		{map[string]string{"key": "123"}, "not-exist", "default", "default"},
 	}
 	for _, c := range cases {
-		ca := cmdArgs{c.params}
+		ca := cmdArgs{params: c.params}
 		assert.Equal(t, c.expected, ca.stringVal(c.key, c.defaultVal))
 	}
 }
 
+func Test_commandArgs_listVal(t *testing.T) {
+	cases := []struct {
+		args     cmdArgs
+		key      string
+		expected []string
+	}{
+		{cmdArgs{params: map[string]string{"key": "a"}}, "key", []string{"a"}},
+		{cmdArgs{params: map[string]string{"key": "b"}, lists: map[string][]string{"key": {"a", "b"}}}, "key", []string{"a", "b"}},
+		{cmdArgs{params: map[string]string{"key": "a"}}, "not-exist", nil},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.args.listVal(c.key))
+	}
+}
+
+func Test_commandArgs_durationVal(t *testing.T) {
+	cases := []struct {
+		params     map[string]string
+		defaultVal time.Duration
+		expected   time.Duration
+	}{
+		{map[string]string{"key": "10"}, time.Second, 10 * time.Second},
+		{map[string]string{"key": "1m30s"}, time.Second, 90 * time.Second},
+		{map[string]string{"key": "invalid"}, time.Second, time.Second},
+		{map[string]string{}, time.Second, time.Second},
+	}
+	for _, c := range cases {
+		ca := cmdArgs{params: c.params}
+		assert.Equal(t, c.expected, ca.durationVal("key", c.defaultVal))
+	}
+}
+
 func Test_CommandArgs_boolVal(t *testing.T) {
 	cases := []struct {
 		params     map[string]string
@@ -58,7 +97,7 @@ func Test_CommandArgs_boolVal(t *testing.T) {
 		{map[string]string{"key": "t"}, "key", false, true},
 	}
 	for _, c := range cases {
-		ca := cmdArgs{c.params}
+		ca := cmdArgs{params: c.params}
 		assert.Equal(t, c.expected, ca.boolVal(c.key, c.defaultVal))
 	}
 }
@@ -71,18 +110,18 @@ func TestRawOutpoutCommandExecute(t *testing.T) {
 	}{
 		{
 			true,
-			cmdExecute{"echo hello", false, formatCode},
+			cmdExecute{Cmd: "echo hello", AttachCmd: false, Format: formatCode},
 			[]string{"hello", ""},
 		},
 		// stderr
 		{
 			false,
-			cmdExecute{"./demo_stderr.py", false, formatCode},
+			cmdExecute{Cmd: "./demo_stderr.py", AttachCmd: false, Format: formatCode},
 			[]string{"this is stderr", ""},
 		},
 		{
 			false,
-			cmdExecute{"./demo_stderr.py", true, formatCode},
+			cmdExecute{Cmd: "./demo_stderr.py", AttachCmd: true, Format: formatCode},
 			[]string{"$ ./demo_stderr.py", "this is stderr", ""},
 		},
 		// command not exist
@@ -94,26 +133,303 @@ func TestRawOutpoutCommandExecute(t *testing.T) {
 		// local path
 		{
 			false,
-			cmdExecute{"./demo.sh", true, formatCode},
+			cmdExecute{Cmd: "./demo.sh", AttachCmd: true, Format: formatCode},
 			[]string{"$ ./demo.sh", "hello-world!", ""},
 		},
 		// complex
 		{
 			false,
-			cmdExecute{"ls | sort | grep \".go\" | head -n 1", false, formatCode},
+			cmdExecute{Cmd: "ls | sort | grep \".go\" | head -n 1", AttachCmd: false, Format: formatCode},
 			[]string{"article.go", ""},
 		},
 	}
 	for _, c := range cases {
-		switch runtime.GOOS {
-		case "windows":
-			if c.supportWindows {
-				assert.Equal(t, c.output, c.cmd.output())
-			}
-		default:
-			assert.Equal(t, c.output, c.cmd.output())
+		if runtime.GOOS == "windows" && !c.supportWindows {
+			continue
 		}
+		actual, err := executeOutput(&c.cmd)
+		assert.Nil(t, err)
+		assert.Equal(t, c.output, actual)
+	}
+}
+
+// executeOutput returns lines of the combined output without format.
+func executeOutput(c *cmdExecute) ([]string, error) {
+	result, err := c.result(context.Background())
+	if err != nil {
+		return nil, err
 	}
+	return c.lines(result.Combined, c.AttachCmd), nil
+}
+
+func TestCommandExecute_timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process group is not supported")
+	}
+
+	args := &cmdArgs{
+		params: map[string]string{"cmd": "sleep 10 & wait # timeout test"},
+		env:    &environment{Options: Options{Timeout: 100 * time.Millisecond}},
+	}
+	c := newCmdExecute(args).(*cmdExecute)
+	assert.Equal(t, 100*time.Millisecond, c.Timeout)
+
+	start := time.Now()
+	_, err := c.Execute(context.Background())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	key, _, _ := c.cacheKey()
+	_, err = os.Stat(newFileCache("").path(key))
+	assert.True(t, os.IsNotExist(err))
+
+	args.params["timeout"] = "1"
+	c = newCmdExecute(args).(*cmdExecute)
+	assert.Equal(t, time.Second, c.Timeout)
+
+	// deadline of the caller is reported instead of the timeout of the command
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	c = newCmdExecute(&cmdArgs{params: map[string]string{"cmd": "sleep 10 & wait # deadline test"}}).(*cmdExecute)
+	_, err = c.Execute(ctx)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "stopped by deadline of the caller after")
+		assert.NotContains(t, err.Error(), "after 0s")
+	}
+}
+
+func TestCommandExecute_exitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bash is required")
+	}
+
+	cmd := "echo out; echo err 1>&2; exit 3 # exit code test"
+	cases := []struct {
+		params   map[string]string
+		expected string
+		ok       bool
+	}{
+		{
+			map[string]string{"cmd": cmd},
+			"```bash\nout\nerr\n```",
+			true,
+		},
+		{
+			map[string]string{"cmd": cmd, "stderr": "code", "show_exit": "true"},
+			"```bash\nout\n```\n\n```bash\nerr\n```\n\n**exit status 3**",
+			true,
+		},
+		{
+			map[string]string{"cmd": cmd, "stderr": "blockquote"},
+			"```bash\nout\n```\n\n> err",
+			true,
+		},
+		{
+			map[string]string{"cmd": cmd, "stderr": "hide", "expect_exit": "3"},
+			"```bash\nout\n```",
+			true,
+		},
+		{
+			map[string]string{"cmd": cmd, "expect_exit": "0"},
+			"",
+			false,
+		},
+		{
+			map[string]string{"cmd": cmd, "stderr": "invalid"},
+			"",
+			false,
+		},
+	}
+	for _, c := range cases {
+		command := newCmdExecute(&cmdArgs{params: c.params})
+		actual, err := command.Execute(context.Background())
+		if c.ok {
+			assert.Nil(t, err)
+			assert.Equal(t, c.expected, actual)
+		} else {
+			assert.NotNil(t, err)
+		}
+	}
+
+}
+
+func TestCommandExecute_cache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bash is required")
+	}
+
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+	input := filepath.Join(dir, "input.txt")
+	ioutil.WriteFile(input, []byte("foo"), 0644)
+
+	env := &environment{Options: Options{CacheDir: filepath.Join(dir, "cache")}}
+	execute := func(params map[string]string) (string, string) {
+		c := newCmdExecute(&cmdArgs{params: params, env: env}).(*cmdExecute)
+		key, _, err := c.cacheKey()
+		assert.Nil(t, err)
+		output, err := c.Execute(context.Background())
+		assert.Nil(t, err)
+		return key, output
+	}
+
+	params := map[string]string{
+		"cmd":         "cat " + input + "; date +%N",
+		"depends":     filepath.Join(dir, "*.txt"),
+		"depends_env": "MAYA_TEST_ENV",
+	}
+	key1, output1 := execute(params)
+	key2, output2 := execute(params)
+	assert.Equal(t, key1, key2)
+	assert.Equal(t, output1, output2)
+
+	// input file is changed
+	ioutil.WriteFile(input, []byte("bar"), 0644)
+	key3, output3 := execute(params)
+	assert.NotEqual(t, key1, key3)
+	assert.NotEqual(t, output1, output3)
+
+	// environment variable is changed
+	os.Setenv("MAYA_TEST_ENV", "1")
+	defer os.Unsetenv("MAYA_TEST_ENV")
+	key4, _ := execute(params)
+	assert.NotEqual(t, key3, key4)
+
+	// ref does not depend on the environment or absolute paths
+	ref := func(params map[string]string) string {
+		_, ref, err := newCmdExecute(&cmdArgs{params: params, env: env}).(*cmdExecute).cacheKey()
+		assert.Nil(t, err)
+		return ref
+	}
+	ref4 := ref(params)
+	os.Setenv("MAYA_TEST_ENV", "2")
+	assert.Equal(t, ref4, ref(params))
+	os.Setenv("MAYA_TEST_ENV", "1")
+
+	relative := map[string]string{"cmd": "cat input.txt", "depends": "*.txt"}
+	env.file = filepath.Join(dir, "doc.md")
+	ref5 := ref(relative)
+	os.Mkdir(filepath.Join(dir, "moved"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "moved", "input.txt"), []byte("bar"), 0644)
+	env.file = filepath.Join(dir, "moved", "doc.md")
+	assert.Equal(t, ref5, ref(relative))
+	env.file = ""
+
+	// refresh
+	env.RefreshCache = true
+	_, output5 := execute(params)
+	env.RefreshCache = false
+	_, output6 := execute(params)
+	assert.Equal(t, output5, output6)
+
+	// cache disabled
+	params["cache"] = "false"
+	key7, output7 := execute(params)
+	_, output8 := execute(params)
+	assert.Equal(t, "", key7)
+	assert.NotEqual(t, output7, output8)
+
+	// missing input
+	params["cache"] = "true"
+	params["depends"] = filepath.Join(dir, "*.csv")
+	c := newCmdExecute(&cmdArgs{params: params, env: env})
+	_, err := c.Execute(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestCommandExecute_environment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bash is required")
+	}
+
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "sub", "input.txt"), []byte("from file\n"), 0644)
+
+	env := &environment{
+		Options: Options{NoCache: true},
+		file:    filepath.Join(dir, "article.md"),
+	}
+	cases := []struct {
+		params   map[string]string
+		lists    map[string][]string
+		expected string
+	}{
+		{map[string]string{"cmd": "basename $(pwd)"}, nil, filepath.Base(dir)},
+		{map[string]string{"cmd": "basename $(pwd)", "cwd": "sub"}, nil, "sub"},
+		{map[string]string{"cmd": "pwd", "cwd": "/"}, nil, "/"},
+		{
+			map[string]string{"cmd": "echo $FOO $BAR"},
+			map[string][]string{"env": {"FOO=1", "BAR=a=b"}},
+			"1 a=b",
+		},
+		{map[string]string{"cmd": "echo ok", "shell": "sh"}, nil, "ok"},
+		{
+			map[string]string{"cmd": "cat"},
+			map[string][]string{"stdin": {"foo", "bar"}},
+			"foo\nbar",
+		},
+		{map[string]string{"cmd": "cat", "cwd": "sub", "stdin_file": "input.txt"}, nil, "from file"},
+	}
+	for _, c := range cases {
+		cmd := newCmdExecute(&cmdArgs{params: c.params, lists: c.lists, env: env}).(*cmdExecute)
+		result, err := cmd.ExecuteImmediately(context.Background())
+		assert.Nil(t, err)
+		if c.expected != "" {
+			assert.Equal(t, c.expected, strings.TrimSpace(result.Combined), c.params["cmd"])
+		}
+		assert.Equal(t, 0, result.ExitCode, c.params["cmd"])
+	}
+
+	invalids := []struct {
+		params map[string]string
+		lists  map[string][]string
+	}{
+		{map[string]string{"cmd": "echo", "shell": "fish"}, nil},
+		{map[string]string{"cmd": "echo"}, map[string][]string{"env": {"FOO"}}},
+		{map[string]string{"cmd": "cat", "stdin_file": "not-exist.txt"}, nil},
+		{map[string]string{"cmd": "cat", "stdin": "a", "stdin_file": "sub/input.txt"}, nil},
+	}
+	for _, c := range invalids {
+		cmd := newCmdExecute(&cmdArgs{params: c.params, lists: c.lists, env: env})
+		_, err := cmd.Execute(context.Background())
+		assert.NotNil(t, err, c.params)
+	}
+}
+
+func TestCommandExecute_python(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is required")
+	}
+	cmd := newCmdExecute(&cmdArgs{
+		params: map[string]string{"cmd": "print(1 + 2)", "shell": "python", "cache": "false"},
+	}).(*cmdExecute)
+	result, err := cmd.ExecuteImmediately(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "3\n", result.Combined)
+}
+
+func TestCommandExecute_cancel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process group is not supported")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := &cmdExecute{Cmd: "sleep 10 # cancel test", Format: formatCode}
+	_, err := c.Execute(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+// viewOutput returns selected lines without format.
+func viewOutput(c *cmdView) ([]string, error) {
+	lines, err := c.lines(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return lineTexts(lines), nil
 }
 
 func TestRawOutputCommandView(t *testing.T) {
@@ -132,17 +448,187 @@ func TestRawOutputCommandView(t *testing.T) {
 		},
 	}
 	for _, c := range cases {
-		assert.Equal(t, c.output, c.cmd.output())
+		actual, err := viewOutput(&c.cmd)
+		assert.Nil(t, err)
+		assert.Equal(t, c.output, actual)
 	}
 }
 
+func TestRawOutputCommandView_lines(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "abc.txt"), []byte("a\nb\nc\nd\ne\n"), 0644)
+	env := &environment{file: filepath.Join(dir, "article.md")}
+
+	cases := []struct {
+		params   map[string]string
+		expected []string
+	}{
+		{map[string]string{"lines": "2-3"}, []string{"b", "c"}},
+		{map[string]string{"lines": "4-"}, []string{"d", "e"}},
+		{map[string]string{"lines": "-2-"}, []string{"d", "e"}},
+		{map[string]string{"lines": "1,3-4"}, []string{"a", "...", "c", "d"}},
+		{map[string]string{"lines": "1-2,3", "elision": "// ..."}, []string{"a", "b", "c"}},
+		{map[string]string{"lines": "1,-1", "elision": "# snip"}, []string{"a", "# snip", "e"}},
+		// legacy zero-based start_line and end_line
+		{map[string]string{"start_line": "3"}, []string{"d", "e", ""}},
+		{map[string]string{"end_line": "2"}, []string{"a", "b"}},
+	}
+	for _, c := range cases {
+		c.params["file"] = "abc.txt"
+		cmd := newCmdView(&cmdArgs{params: c.params, env: env}).(*cmdView)
+		actual, err := viewOutput(cmd)
+		assert.Nil(t, err, c.params)
+		assert.Equal(t, c.expected, actual, c.params)
+	}
+
+	invalids := []map[string]string{
+		{"lines": "0-2"},
+		{"lines": "3-6"},
+		{"lines": "-6-"},
+		{"lines": "4-2"},
+		{"lines": "a-b"},
+		{"lines": "1-2", "start_line": "1"},
+		{"end_line": "10"},
+		{"start_line": "4", "end_line": "2"},
+	}
+	for _, params := range invalids {
+		params["file"] = "abc.txt"
+		cmd := newCmdView(&cmdArgs{params: params, env: env})
+		_, err := cmd.Execute(context.Background())
+		assert.NotNil(t, err, params)
+	}
+}
+
+func TestCommandView_Execute_display(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(strings.Join([]string{
+		"package main",
+		"",
+		"func main() {",
+		"\tif true {",
+		"\t\tprintln(1)",
+		"\t}",
+		"}",
+	}, "\n")), 0644)
+	env := &environment{file: filepath.Join(dir, "article.md")}
+
+	cases := []struct {
+		params   map[string]string
+		expected string
+	}{
+		{
+			map[string]string{"lines": "4-6", "dedent": "true", "tabs": "2"},
+			"```go\nif true {\n  println(1)\n}\n```",
+		},
+		{
+			map[string]string{"lines": "4-6", "dedent": "true", "line_numbers": "true"},
+			"```go\n4  if true {\n5  \tprintln(1)\n6  }\n```",
+		},
+		{
+			map[string]string{"lines": "3,7", "line_numbers": "true", "highlight": "-1", "render": "hugo"},
+			"```go {linenos=table,linenostart=3,hl_lines=[3]}\nfunc main() {\n...\n}\n```",
+		},
+		{
+			map[string]string{"lines": "1-3", "highlight": "2", "render": "pelican"},
+			"```{ .go hl_lines=\"2\" }\npackage main\n\nfunc main() {\n```",
+		},
+		{
+			map[string]string{"lines": "1-3", "line_numbers": "true", "highlight": "1", "render": "zola"},
+			"```go,linenos,linenostart=1,hl_lines=1\npackage main\n\nfunc main() {\n```",
+		},
+	}
+	for _, c := range cases {
+		c.params["file"] = "main.go"
+		cmd := newCmdView(&cmdArgs{params: c.params, env: env})
+		actual, err := cmd.Execute(context.Background())
+		assert.Nil(t, err, c.params)
+		assert.Equal(t, c.expected, actual, c.params)
+	}
+
+	invalids := []map[string]string{
+		{"highlight": "2"},
+		{"highlight": "8", "render": "html"},
+		{"render": "asciidoc"},
+		{"line_numbers": "true", "format": "blockquote"},
+	}
+	for _, params := range invalids {
+		params["file"] = "main.go"
+		cmd := newCmdView(&cmdArgs{params: params, env: env})
+		_, err := cmd.Execute(context.Background())
+		assert.NotNil(t, err, params)
+	}
+}
+
+func TestRawOutputCommandView_symbol(t *testing.T) {
+	c := newCmdView(&cmdArgs{params: map[string]string{
+		"file": "cmd_view.go",
+		"type": "cmdView",
+		"doc":  "false",
+	}}).(*cmdView)
+	actual, err := viewOutput(c)
+	assert.Nil(t, err)
+	assert.Equal(t, "type cmdView struct {", actual[0])
+	assert.Equal(t, "}", actual[len(actual)-1])
+
+	invalids := []map[string]string{
+		{"file": "cmd_view.go", "func": "notExist"},
+		{"file": "cmd_view.go", "func": "newCmdView", "type": "cmdView"},
+		{"file": "cmd_view.go", "func": "newCmdView", "start_line": "1"},
+		{"file": "cmd_view.go", "method": "output"},
+		{"file": "demo.py", "func": "main"},
+	}
+	for _, params := range invalids {
+		c := newCmdView(&cmdArgs{params: params})
+		_, err := c.Execute(context.Background())
+		assert.NotNil(t, err, params)
+	}
+}
+
+func TestRawOutputCommandView_region(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte(strings.Join([]string{
+		"<html>",
+		"<!-- maya:begin body -->",
+		"<body>",
+		"<!-- maya:begin script -->",
+		"<script></script>",
+		"<!-- maya:end script -->",
+		"</body>",
+		"<!-- maya:end body -->",
+		"</html>",
+	}, "\n")), 0644)
+
+	env := &environment{file: filepath.Join(dir, "article.md")}
+	c := newCmdView(&cmdArgs{params: map[string]string{"file": "index.html", "region": "body"}, env: env})
+	actual, err := viewOutput(c.(*cmdView))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"<body>", "<script></script>", "</body>"}, actual)
+
+	c = newCmdView(&cmdArgs{params: map[string]string{"file": "index.html", "region": "head"}, env: env})
+	_, err = c.Execute(context.Background())
+	assert.NotNil(t, err)
+
+	c = newCmdView(&cmdArgs{params: map[string]string{"file": "index.html", "region": "body", "end_line": "3"}, env: env})
+	_, err = c.Execute(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestRawOutputCommandView_notExist(t *testing.T) {
+	c := cmdView{FilePath: "not-exist.txt", Format: formatCode}
+	_, err := viewOutput(&c)
+	assert.NotNil(t, err)
+}
+
 func TestRawOutputCommandUnknown(t *testing.T) {
 	cases := []struct {
 		cmd    cmdUnknown
 		output []string
 	}{
 		{
-			cmdUnknown{"foo", &cmdArgs{map[string]string{}}},
+			cmdUnknown{"foo", &cmdArgs{params: map[string]string{}}},
 			[]string{"Action=foo"},
 		},
 	}
@@ -153,15 +639,15 @@ func TestRawOutputCommandUnknown(t *testing.T) {
 
 func Test_cmdGist(t *testing.T) {
 	cases := []struct {
-		actual   cmd
-		expected cmd
+		actual   Command
+		expected Command
 	}{
 		{
-			newCmdGist(&cmdArgs{map[string]string{
+			newCmdGist(&cmdArgs{params: map[string]string{
 				"id":   "3254906",
 				"file": "brew-update-notifier.sh",
 			}}),
-			&cmdGist{"3254906", "brew-update-notifier.sh"},
+			&cmdGist{ID: "3254906", File: "brew-update-notifier.sh", Cache: true},
 		},
 	}
 	for _, c := range cases {
@@ -171,18 +657,276 @@ func Test_cmdGist(t *testing.T) {
 	}
 }
 
+func TestCommandGist_inline(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "gists", "b23494b9e42ae89e6f28"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "gists", "b23494b9e42ae89e6f28", "factorial.sh"), []byte("echo 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "gists", "b23494b9e42ae89e6f28", "README"), []byte("readme\n"), 0644)
+
+	env := &environment{Options: Options{
+		CacheDir:    filepath.Join(dir, "cache"),
+		GistFetcher: &DirGistFetcher{filepath.Join(dir, "gists")},
+	}}
+	params := map[string]string{"id": "b23494b9e42ae89e6f28", "file": "factorial.sh", "inline": "true"}
+	cmd := newCmdGist(&cmdArgs{params: params, env: env})
+	actual, err := cmd.Execute(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "```sh\necho 1\n```", actual)
+
+	// cached output is used after the mirror is removed
+	os.RemoveAll(filepath.Join(dir, "gists"))
+	actual, err = cmd.Execute(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "```sh\necho 1\n```", actual)
+	key, ref, _ := cmd.(cacher).cacheKey()
+	assert.NotEqual(t, "", key)
+	assert.Equal(t, key, ref)
+
+	env.RefreshCache = true
+	cmd = newCmdGist(&cmdArgs{params: params, env: env})
+	_, err = cmd.Execute(context.Background())
+	assert.NotNil(t, err)
+
+	// script tag is not cached
+	cmd = newCmdGist(&cmdArgs{params: map[string]string{"id": "b23494b9e42ae89e6f28"}, env: env})
+	key, _, _ = cmd.(cacher).cacheKey()
+	assert.Equal(t, "", key)
+}
+
+func TestCommandEmbed(t *testing.T) {
+	cases := []struct {
+		cmd      Command
+		expected string
+	}{
+		{
+			newCmdEmbed(&cmdArgs{params: map[string]string{"url": "https://twitter.com/golang/status/1"}}),
+			`<div class="maya-embed maya-embed-twitter"><blockquote class="twitter-tweet"><a href="https://twitter.com/golang/status/1"></a></blockquote><script async src="https://platform.twitter.com/widgets.js" charset="utf-8"></script></div>`,
+		},
+		{
+			newCmdEmbed(&cmdArgs{params: map[string]string{"url": "https://speakerdeck.com/user/talk", "id": "abc123"}}),
+			`<div class="maya-embed maya-embed-speakerdeck" style="position:relative;width:100%;height:0;padding-bottom:75%;overflow:hidden;"><iframe src="https://speakerdeck.com/player/abc123" style="position:absolute;top:0;left:0;width:100%;height:100%;border:0;" allowfullscreen></iframe></div>`,
+		},
+		{
+			newCmdYoutube(&cmdArgs{params: map[string]string{"video_id": "ESCv5qDuQIA", "width": "480", "height": "320"}}),
+			`<div class="maya-embed maya-embed-youtube" style="position:relative;width:100%;height:0;padding-bottom:66.67%;overflow:hidden;"><iframe src="https://www.youtube.com/embed/ESCv5qDuQIA" style="position:absolute;top:0;left:0;width:100%;height:100%;border:0;" allowfullscreen></iframe></div>`,
+		},
+		{
+			newCmdGist(&cmdArgs{params: map[string]string{"id": "b23494b9e42ae89e6f28"}}),
+			`<div class="maya-embed maya-embed-gist"><script src="https://gist.github.com/b23494b9e42ae89e6f28.js"></script></div>`,
+		},
+	}
+	for _, c := range cases {
+		actual, err := c.cmd.Execute(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, actual)
+	}
+
+	invalids := []Command{
+		newCmdEmbed(&cmdArgs{params: map[string]string{}}),
+		newCmdEmbed(&cmdArgs{params: map[string]string{"url": "https://example.com"}}),
+		newCmdYoutube(&cmdArgs{params: map[string]string{}}),
+		newCmdYoutube(&cmdArgs{params: map[string]string{"video_id": "short"}}),
+		newCmdGist(&cmdArgs{params: map[string]string{}}),
+	}
+	for _, cmd := range invalids {
+		_, err := cmd.Execute(context.Background())
+		assert.NotNil(t, err)
+	}
+}
+
+func TestCommandEmbed_mode(t *testing.T) {
+	cases := []struct {
+		mode     string
+		params   map[string]string
+		expected string
+	}{
+		{ModeHugo, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA"}, "{{< youtube ESCv5qDuQIA >}}"},
+		{ModePelican, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA"}, "{% youtube ESCv5qDuQIA %}"},
+		{"jekyll", map[string]string{"url": "https://youtu.be/ESCv5qDuQIA"}, `{% include youtube.html id="ESCv5qDuQIA" %}`},
+		{ModeHugo, map[string]string{"url": "https://vimeo.com/1"}, "{{< vimeo 1 >}}"},
+		{ModeHexo, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA"}, "{% youtube ESCv5qDuQIA %}"},
+		{ModeZola, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA"}, `{{ youtube(id="ESCv5qDuQIA") }}`},
+		{ModeZola, map[string]string{"url": "https://gist.github.com/if1live/abc", "file": "a.sh"}, `{{ gist(url="https://gist.github.com/if1live/abc", file="a.sh") }}`},
+		{ModeHugo, map[string]string{"url": "https://gist.github.com/if1live/abc", "file": "a.sh"}, `{{< gist if1live abc "a.sh" >}}`},
+		{ModePelican, map[string]string{"url": "https://gist.github.com/if1live/abc", "file": "a.sh"}, "{% gist abc a.sh %}"},
+		{ModeHugo, map[string]string{"url": "https://twitter.com/golang/status/1"}, `{{< tweet user="golang" id="1" >}}`},
+		// gist shortcode of hugo requires user
+		{ModeHugo, map[string]string{"url": "https://gist.github.com/abc"}, `<script src="https://gist.github.com/abc.js"></script>`},
+		// no template for the mode
+		{ModeHugo, map[string]string{"url": "https://codepen.io/team/pen/abc", "ratio": ""}, `<div class="maya-embed maya-embed-codepen"><iframe src="https://codepen.io/team/embed/abc?default-tab=result" style="" loading="lazy" allowfullscreen></iframe></div>`},
+		{ModeEmpty, map[string]string{"url": "https://vimeo.com/1", "ratio": ""}, `<div class="maya-embed maya-embed-vimeo"><iframe src="https://player.vimeo.com/video/1" style="" allow="autoplay; fullscreen" allowfullscreen></iframe></div>`},
+		// boolean params are not true unless they are true
+		{ModeEmpty, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA", "ratio": "", "autoplay": "false", "nocookie": "false"}, `<div class="maya-embed maya-embed-youtube"><iframe src="https://www.youtube.com/embed/ESCv5qDuQIA" style="" allowfullscreen></iframe></div>`},
+		{ModeEmpty, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA", "ratio": "", "autoplay": "true", "nocookie": "1"}, `<div class="maya-embed maya-embed-youtube"><iframe src="https://www.youtube-nocookie.com/embed/ESCv5qDuQIA" style="" allow="autoplay" allowfullscreen></iframe></div>`},
+		{ModeHugo, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA", "autoplay": "false"}, "{{< youtube ESCv5qDuQIA >}}"},
+		{"jekyll", map[string]string{"url": "https://youtu.be/ESCv5qDuQIA", "autoplay": "f", "nocookie": "no"}, `{% include youtube.html id="ESCv5qDuQIA" %}`},
+		{ModeHexo, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA", "nocookie": "false"}, "{% youtube ESCv5qDuQIA %}"},
+		{ModeZola, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA", "autoplay": "True"}, `{{ youtube(id="ESCv5qDuQIA", autoplay=true) }}`},
+	}
+	for _, c := range cases {
+		env := &environment{mode: c.mode}
+		cmd := newCmdEmbed(&cmdArgs{params: c.params, env: env})
+		actual, err := cmd.Execute(context.Background())
+		assert.Nil(t, err, c.params)
+		assert.Equal(t, c.expected, actual, c.params)
+	}
+
+	cmd := newCmdYoutube(&cmdArgs{params: map[string]string{"video_id": "ESCv5qDuQIA"}, env: &environment{mode: ModeHugo}})
+	actual, err := cmd.Execute(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "{{< youtube ESCv5qDuQIA >}}", actual)
+}
+
+func TestCommandEmbed_escape(t *testing.T) {
+	// quotes are escaped in html
+	cmd := newCmdEmbed(&cmdArgs{params: map[string]string{"url": "https://youtu.be/ESCv5qDuQIA", "ratio": "", "loading": `lazy" onload="x`}})
+	actual, err := cmd.Execute(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, `<div class="maya-embed maya-embed-youtube"><iframe src="https://www.youtube.com/embed/ESCv5qDuQIA" style="" loading="lazy&#34; onload=&#34;x" allowfullscreen></iframe></div>`, actual)
+
+	cmd = newCmdEmbed(&cmdArgs{params: map[string]string{"url": "https://gist.github.com/abc", "file": `a"b.sh`}})
+	actual, err = cmd.Execute(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, `<div class="maya-embed maya-embed-gist"><script src="https://gist.github.com/abc.js?file=a%22b.sh"></script></div>`, actual)
+
+	// shortcodes and liquid tags reject values which end arguments
+	invalids := []struct {
+		mode   string
+		params map[string]string
+	}{
+		{ModeHugo, map[string]string{"url": "https://youtu.be/ESCv5qDuQIA", "start": `1" end="2`}},
+		{ModeHugo, map[string]string{"url": "https://gist.github.com/if1live/abc", "file": "a.sh >}}<script>"}},
+		{"jekyll", map[string]string{"url": "https://youtu.be/ESCv5qDuQIA", "loading": "lazy %}"}},
+		{ModePelican, map[string]string{"url": "https://gist.github.com/abc", "file": "a.sh %}{% x"}},
+		{ModeZola, map[string]string{"url": "https://gist.github.com/if1live/abc", "file": "a\nb"}},
+	}
+	for _, c := range invalids {
+		cmd := newCmdEmbed(&cmdArgs{params: c.params, env: &environment{mode: c.mode}})
+		_, err := cmd.Execute(context.Background())
+		assert.NotNil(t, err, c.params)
+	}
+}
+
+func TestCommandYoutube_options(t *testing.T) {
+	cases := []struct {
+		mode     string
+		params   map[string]string
+		expected string
+	}{
+		{
+			"",
+			map[string]string{"video_id": "https://www.youtube.com/watch?v=ESCv5qDuQIA&t=1m30s", "end": "2:00", "autoplay": "true", "loading": "lazy", "nocookie": "true"},
+			`<div class="maya-embed maya-embed-youtube" style="position:relative;width:100%;height:0;padding-bottom:56.25%;overflow:hidden;"><iframe src="https://www.youtube-nocookie.com/embed/ESCv5qDuQIA?autoplay=1&amp;end=120&amp;start=90" style="position:absolute;top:0;left:0;width:100%;height:100%;border:0;" loading="lazy" allow="autoplay" allowfullscreen></iframe></div>`,
+		},
+		{
+			"",
+			map[string]string{"video_id": "https://www.youtube-nocookie.com/embed/ESCv5qDuQIA", "playlist": "aaaaaaaaaaa, bbbbbbbbbbb", "width": "4", "height": "3"},
+			`<div class="maya-embed maya-embed-youtube" style="position:relative;width:100%;height:0;padding-bottom:75%;overflow:hidden;"><iframe src="https://www.youtube-nocookie.com/embed/ESCv5qDuQIA?playlist=aaaaaaaaaaa%2Cbbbbbbbbbbb" style="position:absolute;top:0;left:0;width:100%;height:100%;border:0;" allowfullscreen></iframe></div>`,
+		},
+		{
+			ModeHugo,
+			map[string]string{"video_id": "https://youtu.be/ESCv5qDuQIA", "start": "30", "loading": "lazy"},
+			`{{< youtube id="ESCv5qDuQIA" start="30" loading="lazy" >}}`,
+		},
+		{
+			"jekyll",
+			map[string]string{"video_id": "ESCv5qDuQIA", "start": "1m", "nocookie": "true"},
+			`{% include youtube.html id="ESCv5qDuQIA" start="60" nocookie="1" %}`,
+		},
+		// params which the mode can not express are rendered as html
+		{
+			ModePelican,
+			map[string]string{"video_id": "ESCv5qDuQIA", "start": "30", "width": "4", "height": "3"},
+			`<div class="maya-embed maya-embed-youtube" style="position:relative;width:100%;height:0;padding-bottom:75%;overflow:hidden;"><iframe src="https://www.youtube.com/embed/ESCv5qDuQIA?start=30" style="position:absolute;top:0;left:0;width:100%;height:100%;border:0;" allowfullscreen></iframe></div>`,
+		},
+		{
+			ModeHugo,
+			map[string]string{"video_id": "ESCv5qDuQIA", "nocookie": "true", "width": "4", "height": "3"},
+			`<div class="maya-embed maya-embed-youtube" style="position:relative;width:100%;height:0;padding-bottom:75%;overflow:hidden;"><iframe src="https://www.youtube-nocookie.com/embed/ESCv5qDuQIA" style="position:absolute;top:0;left:0;width:100%;height:100%;border:0;" allowfullscreen></iframe></div>`,
+		},
+		{
+			ModeHexo,
+			map[string]string{"video_id": "ESCv5qDuQIA", "nocookie": "true"},
+			`{% youtube ESCv5qDuQIA video false %}`,
+		},
+		{
+			ModeHugo,
+			map[string]string{"video_id": "ESCv5qDuQIA", "start": "90", "thumbnail": "true", "title": "[demo]"},
+			`[![\[demo\]](https://img.youtube.com/vi/ESCv5qDuQIA/hqdefault.jpg)](https://www.youtube.com/watch?v=ESCv5qDuQIA&t=90s)`,
+		},
+	}
+	for _, c := range cases {
+		cmd := newCmdYoutube(&cmdArgs{params: c.params, env: &environment{mode: c.mode}})
+		actual, err := cmd.Execute(context.Background())
+		assert.Nil(t, err, c.params)
+		assert.Equal(t, c.expected, actual, c.params)
+	}
+
+	invalids := []map[string]string{
+		{"video_id": "https://vimeo.com/1"},
+		{"video_id": "ESCv5qDuQIA!"},
+		{"video_id": "ESCv5qDuQIA", "start": "1.5s"},
+		{"video_id": "ESCv5qDuQIA", "start": "60", "end": "30"},
+		{"video_id": "ESCv5qDuQIA", "playlist": "short"},
+		{"video_id": "ESCv5qDuQIA", "loading": "later"},
+	}
+	for _, params := range invalids {
+		cmd := newCmdYoutube(&cmdArgs{params: params})
+		_, err := cmd.Execute(context.Background())
+		assert.NotNil(t, err, params)
+	}
+}
+
+func Test_parseYoutubeTime(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected int
+	}{
+		{"0", 0},
+		{"90", 90},
+		{"1m30s", 90},
+		{"1h", 3600},
+		{"1:30", 90},
+		{"1:02:03", 3723},
+	}
+	for _, c := range cases {
+		actual, err := parseYoutubeTime(c.text)
+		assert.Nil(t, err, c.text)
+		assert.Equal(t, c.expected, actual, c.text)
+	}
+
+	invalids := []string{"", "-1", "abc", "1:xx", "1:2:3:4", "500ms"}
+	for _, text := range invalids {
+		_, err := parseYoutubeTime(text)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestCommandView_mode(t *testing.T) {
+	params := map[string]string{"file": "demo.py", "lines": "1-2", "line_numbers": "true", "highlight": "2"}
+	cmd := newCmdView(&cmdArgs{params: params, env: &environment{mode: ModeHugo}})
+	actual, err := cmd.Execute(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(actual, "```python {linenos=table,linenostart=1,hl_lines=[2]}"), actual)
+
+	cmd = newCmdView(&cmdArgs{params: params, env: &environment{mode: ModeEmpty}})
+	_, err = cmd.Execute(context.Background())
+	assert.NotNil(t, err)
+}
+
 func Test_cmdYoutube(t *testing.T) {
 	cases := []struct {
-		actual   cmd
-		expected cmd
+		actual   Command
+		expected Command
 	}{
 		{
-			newCmdYoutube(&cmdArgs{map[string]string{
+			newCmdYoutube(&cmdArgs{params: map[string]string{
 				"video_id": "id",
 				"width":    "480",
 				"height":   "320",
 			}}),
-			&cmdYoutube{"id", 480, 320},
+			&cmdYoutube{VideoId: "id", Width: 480, Height: 320, Title: "YouTube video"},
 		},
 	}
 	for _, c := range cases {
@@ -194,28 +938,28 @@ func Test_cmdYoutube(t *testing.T) {
 
 func Test_cmdView(t *testing.T) {
 	cases := []struct {
-		actual   cmd
-		expected cmd
+		actual   Command
+		expected Command
 	}{
 		{
-			newCmdView(&cmdArgs{map[string]string{"file": "hello.txt"}}),
-			&cmdView{"hello.txt", 0, 0, "txt", formatCode},
+			newCmdView(&cmdArgs{params: map[string]string{"file": "hello.txt"}}),
+			&cmdView{FilePath: "hello.txt", Language: "txt", Format: formatCode, Elision: "...", Doc: true},
 		},
 		{
-			newCmdView(&cmdArgs{map[string]string{
+			newCmdView(&cmdArgs{params: map[string]string{
 				"file":       "foo.txt",
 				"start_line": "1",
 				"end_line":   "10",
 				"format":     "blockquote",
 			}}),
-			&cmdView{"foo.txt", 1, 10, "txt", formatBlockquote},
+			&cmdView{FilePath: "foo.txt", StartLine: 1, EndLine: 10, Language: "txt", Format: formatBlockquote, Elision: "...", Doc: true},
 		},
 		{
-			newCmdView(&cmdArgs{map[string]string{
+			newCmdView(&cmdArgs{params: map[string]string{
 				"file": "hello.txt",
 				"lang": "lisp",
 			}}),
-			&cmdView{"hello.txt", 0, 0, "lisp", formatCode},
+			&cmdView{FilePath: "hello.txt", Language: "lisp", Format: formatCode, Elision: "...", Doc: true},
 		},
 	}
 	for _, c := range cases {
@@ -227,29 +971,29 @@ func Test_cmdView(t *testing.T) {
 
 func Test_cmdExecute(t *testing.T) {
 	cases := []struct {
-		actual   cmd
-		expected cmd
+		actual   Command
+		expected Command
 	}{
 		{
-			newCmdExecute(&cmdArgs{map[string]string{
+			newCmdExecute(&cmdArgs{params: map[string]string{
 				"cmd": "echo hello",
 			}}),
-			&cmdExecute{"echo hello", false, formatCode},
+			&cmdExecute{Cmd: "echo hello", AttachCmd: false, Format: formatCode, Stderr: stderrMerge, ExpectExit: -1, Cache: true},
 		},
 		{
-			newCmdExecute(&cmdArgs{map[string]string{
+			newCmdExecute(&cmdArgs{params: map[string]string{
 				"cmd":    "echo hello",
 				"format": "blockquote",
 			}}),
-			&cmdExecute{"echo hello", false, formatBlockquote},
+			&cmdExecute{Cmd: "echo hello", AttachCmd: false, Format: formatBlockquote, Stderr: stderrMerge, ExpectExit: -1, Cache: true},
 		},
 		{
-			newCmdExecute(&cmdArgs{map[string]string{
+			newCmdExecute(&cmdArgs{params: map[string]string{
 				"cmd":        "echo hello",
 				"format":     "blockquote",
 				"attach_cmd": "t",
 			}}),
-			&cmdExecute{"echo hello", true, formatBlockquote},
+			&cmdExecute{Cmd: "echo hello", AttachCmd: true, Format: formatBlockquote, Stderr: stderrMerge, ExpectExit: -1, Cache: true},
 		},
 	}
 	for _, c := range cases {
@@ -259,3 +1003,124 @@ func Test_cmdExecute(t *testing.T) {
 	}
 
 }
+
+type cmdHello struct {
+	Name   string `maya:"name,world"`
+	Repeat int    `maya:"repeat,1"`
+	Upper  bool   `maya:"upper"`
+}
+
+func (c *cmdHello) Execute(ctx context.Context) (string, error) {
+	text := strings.Repeat("hello "+c.Name+"\n", c.Repeat)
+	if c.Upper {
+		text = strings.ToUpper(text)
+	}
+	return strings.TrimRight(text, "\n"), nil
+}
+
+func unregisterCommand(name string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	delete(registry.table, name)
+}
+
+func TestRegisterCommand(t *testing.T) {
+	factory := func() Command { return &cmdHello{} }
+	assert.Nil(t, RegisterCommand("test_hello", factory))
+	defer unregisterCommand("test_hello")
+	assert.NotNil(t, RegisterCommand("test_hello", factory))
+	assert.NotNil(t, RegisterCommand("view", factory))
+	assert.NotNil(t, RegisterCommand("invalid-name", factory))
+	assert.NotNil(t, RegisterCommand("test_nil", nil))
+
+	var nilCmd *cmdHello
+	assert.NotNil(t, RegisterCommand("test_nil_cmd", func() Command { return nilCmd }))
+
+	names := RegisteredCommands()
+	assert.Contains(t, names, "test_hello")
+	assert.Contains(t, names, "view")
+	assert.NotContains(t, names, "test_nil")
+
+	cases := []struct {
+		params   map[string]string
+		expected Command
+	}{
+		{
+			map[string]string{},
+			&cmdHello{Name: "world", Repeat: 1},
+		},
+		{
+			map[string]string{"name": "maya", "repeat": "2", "upper": "true"},
+			&cmdHello{Name: "maya", Repeat: 2, Upper: true},
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, newCmd("test_hello", &cmdArgs{params: c.params}))
+	}
+
+	content := NewContent("~~~maya:test_hello\nname=maya\n~~~")
+	actual, err := content.String()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello maya", actual)
+}
+
+func TestCommandView_rev(t *testing.T) {
+	dir := newGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	env := &environment{file: filepath.Join(dir, "article.md")}
+	cases := []struct {
+		params   map[string]string
+		expected string
+	}{
+		{map[string]string{"file": "src/hello.py", "rev": "v1"}, "```python\nprint('hello')\n```"},
+		{map[string]string{"file": "hello.py", "rev": "v2", "repo": "src", "lines": "2"}, "```python\nprint('world')\n```"},
+		{map[string]string{"file": "src/hello.py"}, "```python\nprint('working tree')\n```"},
+	}
+	for _, c := range cases {
+		cmd := newCmdView(&cmdArgs{params: c.params, env: env})
+		actual, err := cmd.Execute(context.Background())
+		assert.Nil(t, err, c.params)
+		assert.Equal(t, c.expected, actual, c.params)
+	}
+
+	cmd := newCmdView(&cmdArgs{params: map[string]string{"file": "src/hello.py", "rev": "v1"}, env: env})
+	assert.Nil(t, cmd.(*cmdView).dependencies())
+
+	invalids := []map[string]string{
+		{"file": "src/hello.py", "rev": "v3"},
+		{"file": "src/hello.py", "repo": "."},
+	}
+	for _, params := range invalids {
+		cmd := newCmdView(&cmdArgs{params: params, env: env})
+		_, err := cmd.Execute(context.Background())
+		assert.NotNil(t, err, params)
+	}
+}
+
+func TestCommandDiff(t *testing.T) {
+	dir := newGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	env := &environment{file: filepath.Join(dir, "article.md")}
+	cmd := newCmdDiff(&cmdArgs{params: map[string]string{
+		"file": "src/hello.py",
+		"from": "v1",
+		"to":   "v2",
+	}, env: env})
+	actual, err := cmd.Execute(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "```diff\n--- a/src/hello.py\n+++ b/src/hello.py\n@@ -1 +1,2 @@\n print('hello')\n+print('world')\n```", actual)
+
+	invalids := []map[string]string{
+		{"file": "src/hello.py"},
+		{"from": "v1"},
+		{"file": "src/hello.py", "from": "v3"},
+		{"file": "src/hello.py", "from": "v1", "context": "-1"},
+	}
+	for _, params := range invalids {
+		cmd := newCmdDiff(&cmdArgs{params: params, env: env})
+		_, err := cmd.Execute(context.Background())
+		assert.NotNil(t, err, params)
+	}
+}
@@ -1,13 +1,13 @@
 package maya
 
-import "github.com/op/go-logging"
+import "context"
 
 type cmdUnknown struct {
 	Action string
 	Args   *cmdArgs
 }
 
-func newCmdUnknown(action string, args *cmdArgs) cmd {
+func newCmdUnknown(action string, args *cmdArgs) Command {
 	return &cmdUnknown{
 		Action: action,
 		Args:   args,
@@ -15,15 +15,20 @@ func newCmdUnknown(action string, args *cmdArgs) cmd {
 }
 
 func (c *cmdUnknown) output() []string {
-	log := logging.MustGetLogger("maya")
-	log.Warningf("Command Unknown: %v", c)
 	tokens := []string{
 		"Action=" + c.Action,
 	}
 	return tokens
 }
 
-func (c *cmdUnknown) execute() string {
-	f := newFormatter(formatBlockquote)
-	return f.format(c.output())
+func (c *cmdUnknown) Execute(ctx context.Context) (string, error) {
+	f, err := newFormatter(formatBlockquote)
+	if err != nil {
+		return "", err
+	}
+	return f.format(c.output()), nil
+}
+
+func (c *cmdUnknown) Warnings() []string {
+	return []string{"unknown command: " + c.Action}
 }
@@ -1,48 +1,275 @@
 package maya
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/op/go-logging"
 )
 
 type cmdView struct {
-	FilePath  string `maya:"file"`
-	StartLine int    `maya:"start_line,0"`
-	EndLine   int    `maya:"end_line,0"`
-	Language  string
-	Format    string `maya:"format,code"`
+	FilePath string `maya:"file"`
+	// Lines is 1-based ranges like 10-25,30-. -5- is the last five lines.
+	Lines   string `maya:"lines"`
+	Elision string `maya:"elision,..."`
+	// StartLine and EndLine are zero-based and EndLine is exclusive.
+	// they are kept for old documents, use Lines instead.
+	StartLine int `maya:"start_line,0"`
+	EndLine   int `maya:"end_line,0"`
+
+	Language string
+	Format   string `maya:"format,code"`
+
+	Dedent bool `maya:"dedent,false"`
+	// Tabs is width of tab stop. tabs are kept if zero.
+	Tabs int `maya:"tabs,0"`
+	// LineNumbers shows line numbers of the original file.
+	LineNumbers bool `maya:"line_numbers,false"`
+	// Highlight is 1-based ranges of displayed lines like 3,5-7.
+	Highlight string `maya:"highlight"`
+	// Render is markdown/hugo/pelican/html. line numbers and highlight
+	// are written as fence attributes of the target.
+	Render string `maya:"render"`
+
+	// symbol selectors of Go source
+	Func   string `maya:"func"`
+	Type   string `maya:"type"`
+	Method string `maya:"method"`
+	Const  string `maya:"const"`
+	Doc    bool   `maya:"doc,true"`
+
+	// Rev reads the file at git revision instead of the working tree.
+	// Repo is the local clone and file is relative to it if set.
+	Rev  string `maya:"rev"`
+	Repo string `maya:"repo"`
+
+	// Region is the name of `maya:begin name` and `maya:end name` markers.
+	Region string `maya:"region"`
+
+	env environment
 }
 
-func newCmdView(args *cmdArgs) cmd {
+func newCmdView(args *cmdArgs) Command {
 	c := &cmdView{}
 	fillCmd(c, args)
+	c.env = *args.environment()
 	defaultLang := strings.Replace(filepath.Ext(c.FilePath), ".", "", -1)
 	c.Language = args.stringVal("lang", defaultLang)
 	return c
 }
 
-func (c *cmdView) output() []string {
+// lines returns selected lines with tabs expanded and dedented.
+func (c *cmdView) lines(ctx context.Context) ([]sourceLine, error) {
+	lines, err := c.selectLines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.Tabs > 0 {
+		expanded := make([]sourceLine, len(lines))
+		for i, line := range lines {
+			expanded[i] = sourceLine{line.num, expandTabs(line.text, c.Tabs)}
+		}
+		lines = expanded
+	}
+	if c.Dedent {
+		lines = dedentLines(lines)
+	}
+	return lines, nil
+}
+
+// selectLines returns lines picked by selectors with original line numbers.
+func (c *cmdView) selectLines(ctx context.Context) ([]sourceLine, error) {
 	log := logging.MustGetLogger("maya")
 	log.Infof("Command ViewFile: %v", c)
-	data, err := ioutil.ReadFile(c.FilePath)
+	data, err := c.read(ctx)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	lines := strings.Split(string(data[:]), "\n")
+	lines = sanitizeLineFeedMultiLine(lines)
+	numbered := numberLines(lines, 1)
+
+	if err := c.checkSelectors(); err != nil {
+		return nil, err
+	}
+	symbol, err := c.symbol()
+	if err != nil {
+		return nil, err
+	}
+	if symbol != nil {
+		first, last, err := symbol.lineRange(c.FilePath, data)
+		if err != nil {
+			return nil, err
+		}
+		return numbered[first-1 : last], nil
+	}
+	if c.Region != "" {
+		first, last, err := regionRange(lines, c.Region)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", c.FilePath, err)
+		}
+		return stripRegionMarkers(numbered[first-1 : last]), nil
+	}
+	if c.Lines != "" {
+		ranges, err := parseLineRanges(c.Lines, countLines(lines))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", c.FilePath, err)
+		}
+		return selectLineRanges(numbered, ranges, c.Elision), nil
+	}
+
+	start, end := c.StartLine, c.EndLine
+	if end == 0 {
+		end = len(lines)
+	}
+	if start < 0 || end > len(lines) || start > end {
+		return nil, fmt.Errorf("%s: start_line %d and end_line %d are outside of file with %d lines", c.FilePath, c.StartLine, c.EndLine, len(lines))
+	}
+	return numbered[start:end], nil
+}
+
+// read returns content of the file in the working tree or at Rev.
+func (c *cmdView) read(ctx context.Context) ([]byte, error) {
+	if c.Rev == "" {
+		if c.Repo != "" {
+			return nil, fmt.Errorf("repo requires rev")
+		}
+		path, err := c.env.resolve(c.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadFile(path)
+	}
+	path, err := c.env.repoPath(c.Repo, c.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	return gitShow(ctx, path, c.Rev)
+}
+
+// checkSelectors returns error if lines are selected in more than one way.
+func (c *cmdView) checkSelectors() error {
+	selectors := []struct {
+		key  string
+		used bool
+	}{
+		{"func", c.Func != ""},
+		{"type", c.Type != ""},
+		{"method", c.Method != ""},
+		{"const", c.Const != ""},
+		{"region", c.Region != ""},
+		{"lines", c.Lines != ""},
+		{"start_line/end_line", c.StartLine != 0 || c.EndLine != 0},
+	}
+	used := []string{}
+	for _, s := range selectors {
+		if s.used {
+			used = append(used, s.key)
+		}
+	}
+	if len(used) > 1 {
+		return fmt.Errorf("%s cannot be used together", strings.Join(used, ", "))
+	}
+	return nil
+}
+
+// symbol returns the selected Go declaration. nil if nothing is selected.
+func (c *cmdView) symbol() (*goSymbol, error) {
+	selectors := []goSymbol{
+		{Kind: "func", Name: c.Func},
+		{Kind: "type", Name: c.Type},
+		{Kind: "method", Name: c.Method},
+		{Kind: "const", Name: c.Const},
+	}
+	var found *goSymbol
+	for i, s := range selectors {
+		if s.Name != "" {
+			found = &selectors[i]
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	if found.Kind == "method" && !strings.Contains(found.Name, ".") {
+		return nil, fmt.Errorf("method must be Type.Name: %s", found.Name)
+	}
+	found.Doc = c.Doc
+	return found, nil
+}
 
-	if c.StartLine == 0 && c.EndLine == 0 {
-		c.EndLine = len(lines)
+func (c *cmdView) dependencies() []string {
+	if c.Rev != "" {
+		// content of a revision is not changed by editing files
+		return nil
 	}
+	path, err := c.env.resolve(c.FilePath)
+	if err != nil {
+		return nil
+	}
+	return []string{path}
+}
 
-	elems := lines[c.StartLine:c.EndLine]
-	elems = sanitizeLineFeedMultiLine(elems)
-	return elems
+// codeFormatter returns formatter with line numbers and highlight of lines.
+func (c *cmdView) codeFormatter(lines []sourceLine) (*codeFormatter, error) {
+	if !isRenderTarget(c.Render) {
+		return nil, fmt.Errorf("unknown render: %s", c.Render)
+	}
+	render := c.Render
+	if render == "" && isRenderTarget(c.env.mode) {
+		// fence attributes of the metadata mode like hugo
+		render = c.env.mode
+	}
+	f := &codeFormatter{render: render}
+	if c.LineNumbers {
+		f.numbers = make([]int, len(lines))
+		for i, line := range lines {
+			f.numbers[i] = line.num
+		}
+	}
+	if c.Highlight != "" {
+		if render == "" || render == renderMarkdown {
+			return nil, fmt.Errorf("highlight requires render=hugo, pelican, html, jekyll, hexo or zola")
+		}
+		ranges, err := parseLineRanges(c.Highlight, len(lines))
+		if err != nil {
+			return nil, fmt.Errorf("highlight: %v", err)
+		}
+		nums := expandRanges(ranges)
+		sort.Ints(nums)
+		for _, n := range nums {
+			if len(f.highlight) == 0 || f.highlight[len(f.highlight)-1] != n {
+				f.highlight = append(f.highlight, n)
+			}
+		}
+	}
+	return f, nil
 }
 
-func (c *cmdView) execute() string {
-	f := newFormatter(c.Format)
-	return f.format(c.output(), c.Language)
+func (c *cmdView) Execute(ctx context.Context) (string, error) {
+	lines, err := c.lines(ctx)
+	if err != nil {
+		return "", err
+	}
+	if c.Format != formatCode {
+		if c.LineNumbers || c.Highlight != "" || c.Render != "" {
+			return "", fmt.Errorf("line_numbers, highlight and render require format=code")
+		}
+		f, err := newFormatter(c.Format)
+		if err != nil {
+			return "", err
+		}
+		return f.format(lineTexts(lines), c.Language), nil
+	}
+
+	// highlight counts lines from the first displayed line
+	lines = trimBlankLines(lines)
+	f, err := c.codeFormatter(lines)
+	if err != nil {
+		return "", err
+	}
+	return f.format(lineTexts(lines), c.Language), nil
 }
@@ -1,26 +1,196 @@
 package maya
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
 
+// cmdYoutube is maya:embed of youtube provider with video id.
 type cmdYoutube struct {
+	// VideoId is id of the video or URL like https://youtu.be/ESCv5qDuQIA
 	VideoId string `maya:"video_id"`
-	Width   int    `maya:"width,640"`
-	Height  int    `maya:"height,480"`
+	// Width and Height are aspect ratio of responsive wrapper.
+	Width  int `maya:"width,0"`
+	Height int `maya:"height,0"`
+
+	// Start and End are seconds, 1m30s or 1:30.
+	// Start is t= of the URL if not exist.
+	Start    string `maya:"start"`
+	End      string `maya:"end"`
+	Autoplay bool   `maya:"autoplay,false"`
+	// Playlist is comma separated ids of videos played after the video.
+	Playlist string `maya:"playlist"`
+	// Nocookie uses youtube-nocookie.com which stores no cookies before playback.
+	Nocookie bool `maya:"nocookie,false"`
+	// Loading is lazy or eager.
+	Loading string `maya:"loading"`
+
+	// Thumbnail renders the thumbnail linked to the video
+	// for feeds which remove iframes.
+	Thumbnail bool   `maya:"thumbnail,false"`
+	Title     string `maya:"title,YouTube video"`
+
+	env environment
+}
+
+var youtubeIDRe = regexp.MustCompile(`^[\w-]{11}$`)
+
+func newCmdYoutube(args *cmdArgs) Command {
+	c := &cmdYoutube{}
+	fillCmd(c, args)
+	c.env = *args.environment()
+	return c
+}
+
+// video returns id of the video and query of the URL.
+func (c *cmdYoutube) video() (string, url.Values, error) {
+	if c.VideoId == "" {
+		return "", nil, fmt.Errorf("video_id required")
+	}
+	if !strings.Contains(c.VideoId, "/") {
+		if !youtubeIDRe.MatchString(c.VideoId) {
+			return "", nil, fmt.Errorf("invalid video_id: %q", c.VideoId)
+		}
+		return c.VideoId, url.Values{}, nil
+	}
+
+	p, groups := embeds.match(c.VideoId)
+	if p == nil || p.Name != "youtube" {
+		return "", nil, fmt.Errorf("not a youtube URL: %s", c.VideoId)
+	}
+	u, err := url.Parse(c.VideoId)
+	if err != nil {
+		return "", nil, err
+	}
+	query := u.Query()
+	if strings.HasSuffix(u.Hostname(), "youtube-nocookie.com") {
+		query.Set("nocookie", "1")
+	}
+	return groups["id"], query, nil
+}
+
+// params returns parameters of youtube templates.
+// query is the query string of embed URL.
+func (c *cmdYoutube) params(query url.Values) (map[string]string, error) {
+	params := map[string]string{}
+	if c.Width > 0 && c.Height > 0 {
+		params["ratio"] = fmt.Sprintf("%d:%d", c.Width, c.Height)
+	}
+
+	start := c.Start
+	if start == "" {
+		start = query.Get("start")
+	}
+	if start == "" {
+		start = query.Get("t")
+	}
+	embedQuery := url.Values{}
+	times := []struct {
+		key   string
+		value string
+	}{
+		{"start", start},
+		{"end", c.End},
+	}
+	seconds := map[string]int{}
+	for _, t := range times {
+		if t.value == "" {
+			continue
+		}
+		n, err := parseYoutubeTime(t.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", t.key, err)
+		}
+		seconds[t.key] = n
+		params[t.key] = strconv.Itoa(n)
+		embedQuery.Set(t.key, params[t.key])
+	}
+	if end, ok := seconds["end"]; ok && end <= seconds["start"] {
+		return nil, fmt.Errorf("end %s is not after start %s", c.End, start)
+	}
+
+	if c.Autoplay {
+		params["autoplay"] = "1"
+		embedQuery.Set("autoplay", "1")
+	}
+	if c.Playlist != "" {
+		ids := splitList(c.Playlist)
+		for _, id := range ids {
+			if !youtubeIDRe.MatchString(id) {
+				return nil, fmt.Errorf("invalid video id of playlist: %q", id)
+			}
+		}
+		params["playlist"] = strings.Join(ids, ",")
+		embedQuery.Set("playlist", params["playlist"])
+	}
+	if c.Nocookie || query.Get("nocookie") != "" {
+		params["nocookie"] = "1"
+	}
+	switch c.Loading {
+	case "":
+	case "lazy", "eager":
+		params["loading"] = c.Loading
+	default:
+		return nil, fmt.Errorf("invalid loading: %q, expected lazy or eager", c.Loading)
+	}
+	params["query"] = embedQuery.Encode()
+	return params, nil
 }
 
-func newCmdYoutube(args *cmdArgs) cmd {
-	return fillCmd(&cmdYoutube{}, args)
+// thumbnail returns markdown image of the video linked to youtube.
+func (c *cmdYoutube) thumbnail(id string, params map[string]string) string {
+	link := "https://www.youtube.com/watch?v=" + id
+	if start, ok := params["start"]; ok {
+		link += "&t=" + start + "s"
+	}
+	title := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(c.Title)
+	return fmt.Sprintf("[![%s](https://img.youtube.com/vi/%s/hqdefault.jpg)](%s)", title, id, link)
 }
 
-func (c *cmdYoutube) output() []string {
-	return []string{
-		`<div class="maya-youtube">`,
-		fmt.Sprintf(`<iframe width="%d" height="%d" src="//www.youtube.com/embed/%s" frameborder="0" allowfullscreen></iframe>`, c.Width, c.Height, c.VideoId),
-		`</div>`,
+func (c *cmdYoutube) Execute(ctx context.Context) (string, error) {
+	id, query, err := c.video()
+	if err != nil {
+		return "", err
+	}
+	params, err := c.params(query)
+	if err != nil {
+		return "", err
 	}
+	if c.Thumbnail {
+		return c.thumbnail(id, params), nil
+	}
+	return renderEmbed(&c.env, "https://www.youtube.com/watch?v="+id, params)
 }
 
-func (c *cmdYoutube) execute() string {
-	f := newFormatter(formatText)
-	return f.format(c.output())
+// parseYoutubeTime converts 90, 1m30s or 1:30 to seconds.
+func parseYoutubeTime(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return n, nil
+	}
+	invalid := fmt.Errorf("invalid time: %q, expected seconds, 1m30s or 1:30", s)
+	if strings.Contains(s, ":") {
+		tokens := strings.Split(s, ":")
+		if len(tokens) > 3 {
+			return 0, invalid
+		}
+		seconds := 0
+		for _, token := range tokens {
+			n, err := strconv.Atoi(token)
+			if err != nil || n < 0 {
+				return 0, invalid
+			}
+			seconds = seconds*60 + n
+		}
+		return seconds, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 || d%time.Second != 0 {
+		return 0, invalid
+	}
+	return int(d / time.Second), nil
 }
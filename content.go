@@ -1,55 +1,151 @@
 package maya
 
 import (
+	"context"
+	"fmt"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/op/go-logging"
 )
 
 type ArticleContent struct {
+	// File is the source file used in error messages.
+	File string
+	// Mode is MetadataMode of the article.
+	// embeds are rendered as shortcodes of the mode.
+	Mode string
+	Options
+
 	raw    string
 	blocks []ContentBlock
+
+	parseDiagnostics []Diagnostic
+	evalDiagnostics  []Diagnostic
 }
 
 type ContentBlock struct {
 	command string
 	lines   []string
+	params  []BlockParam
+
+	start Position
+	end   Position
+}
+
+// BlockParam is a key=value line in maya block.
+type BlockParam struct {
+	Key      string
+	Value    string
+	Pos      Position
+	ValuePos Position
+}
+
+var paramRe = regexp.MustCompile(`^(\w+)\s*=(.*)$`)
+
+// Command returns the action of maya block. empty if the block is text.
+func (cb *ContentBlock) Command() string {
+	return cb.command
+}
+
+// Start returns the position of the first character.
+func (cb *ContentBlock) Start() Position {
+	return cb.start
 }
 
-func (cb *ContentBlock) Lines() []string {
+// End returns the position after the last character.
+func (cb *ContentBlock) End() Position {
+	return cb.end
+}
+
+// Params returns the parameters of maya block in order.
+func (cb *ContentBlock) Params() []BlockParam {
+	return cb.params
+}
+
+// serial reports whether the block should run after previous serial blocks.
+func (cb *ContentBlock) serial() bool {
+	return cb.args().boolVal("serial", false)
+}
+
+func (cb *ContentBlock) args() *cmdArgs {
+	params := map[string]string{}
+	lists := map[string][]string{}
+	for _, p := range cb.params {
+		params[p.Key] = p.Value
+		lists[p.Key] = append(lists[p.Key], p.Value)
+	}
+	return &cmdArgs{params: params, lists: lists}
+}
+
+func (cb *ContentBlock) Lines() ([]string, error) {
+	lines, _, err := cb.execute(context.Background(), nil)
+	return lines, err
+}
+
+func (cb *ContentBlock) execute(ctx context.Context, env *environment) ([]string, []string, error) {
 	if cb.command == "" {
-		return cb.lines
+		return cb.lines, nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	re := regexp.MustCompile(`^(\w+)\s*=(.*)$`)
-	params := map[string]string{}
-	for _, line := range cb.lines {
-		m := re.FindStringSubmatch(line)
-		if len(m) == 0 {
-			continue
-		}
-		key, value := m[1], m[2]
-		params[key] = value
+	args := cb.args()
+	args.env = env
+	cmd := newCmd(cb.command, args)
+	text, err := cmd.Execute(ctx)
+	warnings := []string{}
+	if w, ok := cmd.(Warner); ok {
+		warnings = w.Warnings()
 	}
-	cmd := newCmd(cb.command, &cmdArgs{params})
-	return []string{cmd.execute()}
+	if err != nil {
+		return nil, warnings, err
+	}
+	return []string{text}, warnings, nil
 }
 
 func NewContent(text string) *ArticleContent {
+	return newContent(text, 1)
+}
+
+// newContent parses text which begins at firstLine of the source file.
+func newContent(text string, firstLine int) *ArticleContent {
 	rawlines := strings.Split(text, "\n")
 
 	cmdStartRe := regexp.MustCompile(`^~~~maya:(\w+)\s*$`)
 	cmdEndRe := regexp.MustCompile(`^~~~\s*$`)
 	buffer := []string{}
 	blocks := []ContentBlock{}
+	diagnostics := []Diagnostic{}
 
 	state := ""
+	bufferLine := firstLine
 
-	for _, line := range rawlines {
+	flush := func(nextLine int) {
+		block := newContentBlock(state, buffer, bufferLine)
+		for _, line := range block.invalidParamLines() {
+			diagnostics = append(diagnostics, Diagnostic{
+				Severity: SeverityWarning,
+				Start:    Position{line, 1},
+				End:      Position{line + 1, 1},
+				Message:  fmt.Sprintf("maya:%s: invalid parameter: %q", state, buffer[line-bufferLine]),
+			})
+		}
+		blocks = append(blocks, block)
+		bufferLine = nextLine
+	}
+
+	for i, line := range rawlines {
+		lineNum := firstLine + i
 		switch state {
 		case "":
 			m := cmdStartRe.FindStringSubmatch(line)
 			if len(m) > 0 {
-				blocks = append(blocks, ContentBlock{state, buffer})
+				flush(lineNum)
 
 				state = m[1]
 				buffer = []string{line}
@@ -60,7 +156,7 @@ func NewContent(text string) *ArticleContent {
 			m := cmdEndRe.FindString(line)
 			if m != "" {
 				buffer = append(buffer, line)
-				blocks = append(blocks, ContentBlock{state, buffer})
+				flush(lineNum + 1)
 
 				state = ""
 				buffer = []string{}
@@ -69,18 +165,268 @@ func NewContent(text string) *ArticleContent {
 			}
 		}
 	}
-	blocks = append(blocks, ContentBlock{state, buffer})
+	if state != "" {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity: SeverityWarning,
+			Start:    Position{bufferLine, 1},
+			End:      Position{bufferLine, utf8.RuneCountInString(buffer[0]) + 1},
+			Message:  fmt.Sprintf("maya:%s: unterminated block", state),
+		})
+	}
+	flush(firstLine + len(rawlines))
 
 	return &ArticleContent{
-		raw:    text,
-		blocks: blocks,
+		raw:              text,
+		blocks:           blocks,
+		parseDiagnostics: diagnostics,
 	}
 }
 
-func (c *ArticleContent) String() string {
-	lines := []string{}
+func newContentBlock(command string, lines []string, firstLine int) ContentBlock {
+	block := ContentBlock{
+		command: command,
+		lines:   lines,
+		start:   Position{firstLine, 1},
+		end:     Position{firstLine, 1},
+	}
+	if len(lines) > 0 {
+		last := lines[len(lines)-1]
+		block.end = Position{firstLine + len(lines) - 1, utf8.RuneCountInString(last) + 1}
+	}
+	if command == "" {
+		return block
+	}
+
+	params := []BlockParam{}
+	for i, line := range lines {
+		m := paramRe.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		lineNum := firstLine + i
+		params = append(params, BlockParam{
+			Key:      line[m[2]:m[3]],
+			Value:    line[m[4]:m[5]],
+			Pos:      Position{lineNum, columnOf(line, m[2])},
+			ValuePos: Position{lineNum, columnOf(line, m[4])},
+		})
+	}
+	block.params = params
+	return block
+}
+
+// invalidParamLines returns line numbers which are neither fences nor parameters.
+func (cb *ContentBlock) invalidParamLines() []int {
+	if cb.command == "" {
+		return nil
+	}
+	found := []int{}
+	for i, line := range cb.lines {
+		if i == 0 || (i == len(cb.lines)-1 && strings.HasPrefix(line, "~~~")) {
+			continue
+		}
+		if strings.TrimSpace(line) == "" || paramRe.MatchString(line) {
+			continue
+		}
+		found = append(found, cb.start.Line+i)
+	}
+	return found
+}
+
+// Blocks returns the parsed blocks in order.
+func (c *ArticleContent) Blocks() []ContentBlock {
+	return c.blocks
+}
+
+// Diagnostics returns problems found while parsing and evaluating.
+// Evaluation problems are available after String is called.
+func (c *ArticleContent) Diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, d := range c.parseDiagnostics {
+		d.File = c.File
+		diagnostics = append(diagnostics, d)
+	}
+	return append(diagnostics, c.evalDiagnostics...)
+}
+
+type blockResult struct {
+	lines    []string
+	warnings []string
+	err      error
+	// canceled is true if the block is stopped or skipped
+	// because another block failed.
+	canceled bool
+}
+
+func (c *ArticleContent) environment() *environment {
+	return &environment{
+		Options: c.Options,
+		file:    c.File,
+		mode:    c.Mode,
+	}
+}
+
+// commands creates commands of maya blocks without executing them.
+func (c *ArticleContent) commands() []Command {
+	env := c.environment()
+	cmds := []Command{}
 	for _, block := range c.blocks {
-		lines = append(lines, block.Lines()...)
+		if block.command == "" {
+			continue
+		}
+		args := block.args()
+		args.env = env
+		cmds = append(cmds, newCmd(block.command, args))
+	}
+	return cmds
+}
+
+// evaluate executes blocks with bounded workers.
+// blocks are started in document order and results keep the order.
+// serial blocks wait until the previous serial block is finished.
+// unless CollectErrors is set, the first failure cancels the other blocks.
+func (c *ArticleContent) evaluate(ctx context.Context) []blockResult {
+	env := c.environment()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var failed int32
+
+	// blocks run one by one unless concurrency is asked
+	workers := c.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	type task struct {
+		index int
+		wait  <-chan struct{}
+		done  chan struct{}
+	}
+
+	results := make([]blockResult, len(c.blocks))
+	tasks := make(chan task)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				if t.wait != nil {
+					<-t.wait
+				}
+				results[t.index] = c.run(ctx, env, t.index, &failed, cancel)
+				if t.done != nil {
+					close(t.done)
+				}
+			}
+		}()
+	}
+
+	var prevSerial chan struct{}
+	for i, block := range c.blocks {
+		if block.command == "" {
+			results[i] = blockResult{lines: block.lines}
+			continue
+		}
+		t := task{index: i}
+		if block.serial() {
+			t.wait = prevSerial
+			t.done = make(chan struct{})
+			prevSerial = t.done
+		}
+		tasks <- t
+	}
+	close(tasks)
+	wg.Wait()
+
+	return results
+}
+
+// run executes the block unless another block failed.
+// the first failure sets failed and cancels ctx if CollectErrors is false.
+func (c *ArticleContent) run(ctx context.Context, env *environment, index int, failed *int32, cancel func()) blockResult {
+	if atomic.LoadInt32(failed) != 0 {
+		return blockResult{err: context.Canceled, canceled: true}
+	}
+	lines, warnings, err := c.blocks[index].execute(ctx, env)
+	result := blockResult{lines, warnings, err, false}
+	if err != nil && !c.CollectErrors {
+		if atomic.CompareAndSwapInt32(failed, 0, 1) {
+			cancel()
+		} else {
+			result.canceled = true
+		}
+	}
+	return result
+}
+
+func (c *ArticleContent) String() (string, error) {
+	return c.StringContext(context.Background())
+}
+
+// StringContext is like String, but commands are canceled when ctx is done.
+func (c *ArticleContent) StringContext(ctx context.Context) (string, error) {
+	log := logging.MustGetLogger("maya")
+
+	lines := []string{}
+	errs := ErrorList{}
+	diagnostics := []Diagnostic{}
+
+	results := c.evaluate(ctx)
+
+	blockNum := 0
+	for i, block := range c.blocks {
+		if block.command != "" {
+			blockNum++
+		}
+		if results[i].canceled {
+			// the block which failed first is reported
+			continue
+		}
+		blockLines, warnings, err := results[i].lines, results[i].warnings, results[i].err
+		for _, w := range warnings {
+			diagnostics = append(diagnostics, Diagnostic{
+				Severity: SeverityWarning,
+				File:     c.File,
+				Start:    block.start,
+				End:      block.end,
+				Message:  fmt.Sprintf("maya:%s: %s", block.command, w),
+			})
+		}
+		if err != nil {
+			diagnostics = append(diagnostics, Diagnostic{
+				Severity: SeverityError,
+				File:     c.File,
+				Start:    block.start,
+				End:      block.end,
+				Message:  fmt.Sprintf("maya:%s: %v", block.command, err),
+			})
+			err = &Error{
+				File:   c.File,
+				Line:   block.start.Line,
+				Column: block.start.Column,
+				Block:  blockNum,
+				Action: block.command,
+				Err:    err,
+			}
+			if !c.CollectErrors {
+				c.evalDiagnostics = diagnostics
+				return "", err
+			}
+			errs = append(errs, err)
+		}
+		lines = append(lines, blockLines...)
+	}
+
+	c.evalDiagnostics = diagnostics
+	for _, d := range c.Diagnostics() {
+		if d.Severity == SeverityWarning {
+			log.Warning(d.String())
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		return "", err
 	}
-	return strings.Join(lines, "\n")
+	return strings.Join(lines, "\n"), nil
 }
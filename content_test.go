@@ -1,8 +1,13 @@
 package maya
 
 import (
+	"context"
+	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -18,7 +23,12 @@ hello
 world
 `, "\n"),
 			[]ContentBlock{
-				{"", []string{"hello", "world"}},
+				{
+					command: "",
+					lines:   []string{"hello", "world"},
+					start:   Position{1, 1},
+					end:     Position{2, 6},
+				},
 			},
 		},
 		{
@@ -30,9 +40,27 @@ file=x.py
 world
 `, "\n"),
 			[]ContentBlock{
-				{"", []string{"hello"}},
-				{"view", []string{"~~~maya:view", "file=x.py", "~~~"}},
-				{"", []string{"world"}},
+				{
+					command: "",
+					lines:   []string{"hello"},
+					start:   Position{1, 1},
+					end:     Position{1, 6},
+				},
+				{
+					command: "view",
+					lines:   []string{"~~~maya:view", "file=x.py", "~~~"},
+					params: []BlockParam{
+						{"file", "x.py", Position{3, 1}, Position{3, 6}},
+					},
+					start: Position{2, 1},
+					end:   Position{4, 4},
+				},
+				{
+					command: "",
+					lines:   []string{"world"},
+					start:   Position{5, 1},
+					end:     Position{5, 6},
+				},
 			},
 		},
 		{
@@ -42,13 +70,228 @@ hello
 file=x.py
 `, "\n"),
 			[]ContentBlock{
-				{"", []string{"hello"}},
-				{"view", []string{"~~~maya:view", "file=x.py"}},
+				{
+					command: "",
+					lines:   []string{"hello"},
+					start:   Position{1, 1},
+					end:     Position{1, 6},
+				},
+				{
+					command: "view",
+					lines:   []string{"~~~maya:view", "file=x.py"},
+					params: []BlockParam{
+						{"file", "x.py", Position{3, 1}, Position{3, 6}},
+					},
+					start: Position{2, 1},
+					end:   Position{3, 10},
+				},
 			},
 		},
 	}
 	for _, c := range cases {
 		content := NewContent(c.text)
-		assert.Equal(t, c.blocks, content.blocks)
+		assert.Equal(t, c.blocks, content.Blocks())
 	}
 }
+
+func TestNewContent_params(t *testing.T) {
+	text := strings.Join([]string{
+		"~~~maya:view",
+		"file=한글.txt",
+		"lang =go",
+		"~~~",
+	}, "\n")
+	content := newContent(text, 10)
+	block := content.Blocks()[1]
+	assert.Equal(t, "view", block.Command())
+	assert.Equal(t, Position{10, 1}, block.Start())
+	assert.Equal(t, Position{13, 4}, block.End())
+	assert.Equal(t, []BlockParam{
+		{"file", "한글.txt", Position{11, 1}, Position{11, 6}},
+		{"lang", "go", Position{12, 1}, Position{12, 7}},
+	}, block.Params())
+}
+
+func TestContentBlock_args_repeated(t *testing.T) {
+	text := strings.Join([]string{
+		"~~~maya:execute",
+		"env=A=1",
+		"env=B=2",
+		"cmd=env",
+		"~~~",
+	}, "\n")
+	block := NewContent(text).Blocks()[1]
+	args := block.args()
+	assert.Equal(t, "B=2", args.stringVal("env", ""))
+	assert.Equal(t, []string{"A=1", "B=2"}, args.listVal("env"))
+	assert.Equal(t, []string{"env"}, args.listVal("cmd"))
+}
+
+func TestArticleContent_Diagnostics(t *testing.T) {
+	text := strings.Join([]string{
+		"hello",
+		"~~~maya:foo",
+		"this is not param",
+		"~~~",
+		"~~~maya:view",
+		"file=x.py",
+	}, "\n")
+	content := NewContent(text)
+	content.File = "a.md"
+	_, err := content.String()
+	assert.NotNil(t, err)
+
+	diagnostics := content.Diagnostics()
+	assert.Len(t, diagnostics, 4)
+
+	assert.Equal(t, SeverityWarning, diagnostics[0].Severity)
+	assert.Equal(t, Position{3, 1}, diagnostics[0].Start)
+	assert.Equal(t, "a.md", diagnostics[0].File)
+
+	assert.Equal(t, Position{5, 1}, diagnostics[1].Start)
+	assert.Contains(t, diagnostics[1].Message, "unterminated")
+
+	assert.Equal(t, Position{2, 1}, diagnostics[2].Start)
+	assert.Equal(t, Position{4, 4}, diagnostics[2].End)
+	assert.Equal(t, "a.md:2:1: warning: maya:foo: unknown command: foo", diagnostics[2].String())
+
+	assert.Equal(t, SeverityError, diagnostics[3].Severity)
+	assert.Equal(t, Position{5, 1}, diagnostics[3].Start)
+}
+
+type cmdBarrier struct {
+	Name string `maya:"name"`
+}
+
+// barrierState releases blocks when target blocks are running at once.
+// blocks are stuck until timeout if they are not run in parallel.
+var barrierState = struct {
+	sync.Mutex
+	running    int
+	maxRunning int
+	arrived    int
+	target     int
+	release    chan struct{}
+	finished   []string
+}{}
+
+func (c *cmdBarrier) Execute(ctx context.Context) (string, error) {
+	barrierState.Lock()
+	barrierState.running++
+	if barrierState.running > barrierState.maxRunning {
+		barrierState.maxRunning = barrierState.running
+	}
+	barrierState.arrived++
+	if barrierState.arrived == barrierState.target {
+		close(barrierState.release)
+	}
+	release := barrierState.release
+	barrierState.Unlock()
+
+	var err error
+	select {
+	case <-release:
+	case <-time.After(5 * time.Second):
+		err = fmt.Errorf("%d blocks are not running at once", barrierState.target)
+	}
+
+	barrierState.Lock()
+	barrierState.running--
+	barrierState.finished = append(barrierState.finished, c.Name)
+	barrierState.Unlock()
+	return c.Name, err
+}
+
+func TestArticleContent_String_parallel(t *testing.T) {
+	RegisterCommand("test_barrier", func() Command { return &cmdBarrier{} })
+	defer unregisterCommand("test_barrier")
+
+	cases := []struct {
+		workers int
+		serial  bool
+		// blocks which should run at once
+		barrier int
+	}{
+		// zero value runs blocks one by one
+		{0, false, 1},
+		{1, false, 1},
+		{4, false, 4},
+		{4, true, 1},
+	}
+	for _, c := range cases {
+		lines := []string{}
+		expected := []string{}
+		for i := 0; i < 8; i++ {
+			name := fmt.Sprintf("block-%d", i)
+			lines = append(lines, "~~~maya:test_barrier", "name="+name)
+			if c.serial {
+				lines = append(lines, "serial=true")
+			}
+			lines = append(lines, "~~~")
+			expected = append(expected, name)
+		}
+
+		barrierState.maxRunning = 0
+		barrierState.arrived = 0
+		barrierState.target = c.barrier
+		barrierState.release = make(chan struct{})
+		barrierState.finished = nil
+
+		content := NewContent(strings.Join(lines, "\n"))
+		content.Workers = c.workers
+		actual, err := content.String()
+		assert.Nil(t, err)
+		assert.Equal(t, strings.Join(expected, "\n"), strings.Trim(actual, "\n"))
+		assert.True(t, barrierState.maxRunning <= c.barrier, "%d blocks ran with %d workers", barrierState.maxRunning, c.workers)
+		if c.serial || c.workers <= 1 {
+			assert.Equal(t, 1, barrierState.maxRunning)
+			assert.Equal(t, expected, barrierState.finished)
+		}
+	}
+}
+
+type cmdWaitCancel struct{}
+
+var waitCancelStarted int32
+
+func (c *cmdWaitCancel) Execute(ctx context.Context) (string, error) {
+	atomic.AddInt32(&waitCancelStarted, 1)
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(5 * time.Second):
+		return "not canceled", nil
+	}
+}
+
+type cmdFail struct{}
+
+func (c *cmdFail) Execute(ctx context.Context) (string, error) {
+	return "", fmt.Errorf("failed")
+}
+
+func TestArticleContent_String_cancelOnError(t *testing.T) {
+	RegisterCommand("test_wait_cancel", func() Command { return &cmdWaitCancel{} })
+	defer unregisterCommand("test_wait_cancel")
+	RegisterCommand("test_fail", func() Command { return &cmdFail{} })
+	defer unregisterCommand("test_fail")
+
+	lines := []string{"~~~maya:test_wait_cancel", "~~~", "~~~maya:test_fail", "~~~"}
+	for i := 0; i < 6; i++ {
+		lines = append(lines, "~~~maya:test_wait_cancel", "~~~")
+	}
+	atomic.StoreInt32(&waitCancelStarted, 0)
+
+	content := NewContent(strings.Join(lines, "\n"))
+	content.Workers = 2
+	start := time.Now()
+	_, err := content.String()
+	assert.True(t, time.Since(start) < 5*time.Second)
+	// the failed block is reported, not the canceled ones
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, "test_fail", err.(*Error).Action)
+		assert.Equal(t, 2, err.(*Error).Block)
+	}
+	// remaining blocks are not started
+	assert.True(t, atomic.LoadInt32(&waitCancelStarted) <= 2)
+}
@@ -0,0 +1,71 @@
+package maya
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Position is a location in a source file.
+// Line and Column are 1-based. Column counts runes.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func columnOf(line string, byteOffset int) int {
+	return utf8.RuneCountInString(line[:byteOffset]) + 1
+}
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// Diagnostic is a problem found in an article.
+// Start and End are positions in the original file, End is exclusive.
+type Diagnostic struct {
+	Severity Severity
+	File     string
+	Start    Position
+	End      Position
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	file := d.File
+	if file == "" {
+		file = "<input>"
+	}
+	if d.Start.IsValid() {
+		return fmt.Sprintf("%s:%v: %v: %s", file, d.Start, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %v: %s", file, d.Severity, d.Message)
+}
+
+// Warner is implemented by commands which report non-fatal problems.
+// Warnings are called after Execute.
+type Warner interface {
+	Warnings() []string
+}
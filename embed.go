@@ -0,0 +1,381 @@
+package maya
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// EmbedProvider renders content of a service like YouTube for maya:embed.
+type EmbedProvider struct {
+	Name string
+	// Patterns are regular expressions of URLs of the service.
+	// named groups id, user and slug are passed to templates.
+	Patterns []string
+	// Ratio is width:height like 16:9 of responsive wrapper.
+	// if empty, content is not wrapped.
+	Ratio string
+	// Templates are text/template of render target, which is the metadata mode
+	// like hugo or pelican. html is required and used for modes without template.
+	// templates get .URL, .ID, .User, .Slug, .Style and .Params.
+	// boolean params like autoplay are "1" if true and missing if false.
+	Templates map[string]string
+	// Unsupported lists params which the template of a target can not express.
+	// html is rendered instead if any of them is set.
+	Unsupported map[string][]string
+}
+
+// embedData is passed to templates of provider.
+type embedData struct {
+	URL  string
+	ID   string
+	User string
+	Slug string
+	// Style makes iframe fill the responsive wrapper.
+	Style string
+	// Params are parameters of maya:embed block.
+	Params map[string]string
+}
+
+type embedProvider struct {
+	EmbedProvider
+	patterns    []*regexp.Regexp
+	templates   map[string]*template.Template
+	unsupported map[string][]string
+}
+
+type embedRegistry struct {
+	mutex sync.RWMutex
+	// providers are matched in order.
+	// providers registered later come first to override builtins.
+	providers []*embedProvider
+}
+
+const embedIframeStyle = "position:absolute;top:0;left:0;width:100%;height:100%;border:0;"
+
+var embeds = &embedRegistry{}
+
+func init() {
+	builtins := []EmbedProvider{
+		{
+			Name: "youtube",
+			Patterns: []string{
+				`^https?://(?:www\.|m\.)?youtube\.com/watch\?(?:.*&)?v=(?P<id>[\w-]{11})`,
+				`^https?://(?:www\.)?youtube\.com/(?:embed|shorts|v)/(?P<id>[\w-]{11})`,
+				`^https?://youtu\.be/(?P<id>[\w-]{11})`,
+				`^https?://(?:www\.)?youtube-nocookie\.com/embed/(?P<id>[\w-]{11})`,
+			},
+			Ratio: "16:9",
+			// maya:youtube passes start, end, autoplay, playlist, nocookie, loading
+			// and query which is the query string of embed URL
+			Templates: map[string]string{
+				renderHTML:    `<iframe src="https://www.{{if .Params.nocookie}}youtube-nocookie{{else}}youtube{{end}}.com/embed/{{.ID}}{{with .Params.query}}?{{html .}}{{end}}" style="{{.Style}}"{{with .Params.loading}} loading="{{html .}}"{{end}}{{if .Params.autoplay}} allow="autoplay"{{end}} allowfullscreen></iframe>`,
+				renderHugo:    `{{"{{<"}} youtube {{if or .Params.start .Params.end .Params.autoplay .Params.loading}}id="{{.ID}}"{{with .Params.start}} start="{{.}}"{{end}}{{with .Params.end}} end="{{.}}"{{end}}{{if .Params.autoplay}} autoplay="true"{{end}}{{with .Params.loading}} loading="{{.}}"{{end}}{{else}}{{.ID}}{{end}} {{">}}"}}`,
+				renderPelican: `{% youtube {{.ID}} %}`,
+				renderJekyll:  `{% include youtube.html id="{{.ID}}"{{with .Params.start}} start="{{.}}"{{end}}{{with .Params.end}} end="{{.}}"{{end}}{{with .Params.autoplay}} autoplay="{{.}}"{{end}}{{with .Params.playlist}} playlist="{{.}}"{{end}}{{with .Params.nocookie}} nocookie="{{.}}"{{end}}{{with .Params.loading}} loading="{{.}}"{{end}} %}`,
+				renderHexo:    `{% youtube {{.ID}}{{if .Params.nocookie}} video false{{end}} %}`,
+				renderZola:    `{{"{{"}} youtube(id="{{.ID}}"{{if .Params.autoplay}}, autoplay=true{{end}}) {{"}}"}}`,
+			},
+			// privacy and playlist of hugo are site config
+			Unsupported: map[string][]string{
+				renderHugo:    {"playlist", "nocookie"},
+				renderPelican: {"start", "end", "autoplay", "playlist", "nocookie", "loading"},
+				renderHexo:    {"start", "end", "autoplay", "playlist", "loading"},
+				renderZola:    {"start", "end", "playlist", "nocookie", "loading"},
+			},
+		},
+		{
+			Name: "vimeo",
+			Patterns: []string{
+				`^https?://(?:www\.)?vimeo\.com/(?:channels/[\w-]+/)?(?P<id>\d+)`,
+				`^https?://player\.vimeo\.com/video/(?P<id>\d+)`,
+			},
+			Ratio: "16:9",
+			Templates: map[string]string{
+				renderHTML:    `<iframe src="https://player.vimeo.com/video/{{.ID}}" style="{{.Style}}" allow="autoplay; fullscreen" allowfullscreen></iframe>`,
+				renderHugo:    `{{"{{<"}} vimeo {{.ID}} {{">}}"}}`,
+				renderPelican: `{% vimeo {{.ID}} %}`,
+				renderJekyll:  `{% include vimeo.html id="{{.ID}}" %}`,
+				renderHexo:    `{% vimeo {{.ID}} %}`,
+				renderZola:    `{{"{{"}} vimeo(id="{{.ID}}") {{"}}"}}`,
+			},
+		},
+		{
+			Name: "gist",
+			Patterns: []string{
+				`^https?://gist\.github\.com/(?:(?P<user>[\w-]+)/)?(?P<id>[0-9a-f]+)`,
+			},
+			Templates: map[string]string{
+				renderHTML: `<script src="https://gist.github.com/{{.ID}}.js{{with .Params.file}}?file={{urlquery .}}{{end}}"></script>`,
+				// gist shortcode of hugo requires user
+				renderHugo:    `{{if .User}}{{"{{<"}} gist {{.User}} {{.ID}}{{with .Params.file}} "{{.}}"{{end}} {{">}}"}}{{else}}<script src="https://gist.github.com/{{.ID}}.js{{with .Params.file}}?file={{urlquery .}}{{end}}"></script>{{end}}`,
+				renderPelican: `{% gist {{.ID}}{{with .Params.file}} {{.}}{{end}} %}`,
+				renderJekyll:  `{% gist {{.ID}}{{with .Params.file}} {{.}}{{end}} %}`,
+				// gist shortcode of zola requires user
+				renderZola: `{{if .User}}{{"{{"}} gist(url="https://gist.github.com/{{.User}}/{{.ID}}"{{with .Params.file}}, file="{{.}}"{{end}}) {{"}}"}}{{else}}<script src="https://gist.github.com/{{.ID}}.js{{with .Params.file}}?file={{urlquery .}}{{end}}"></script>{{end}}`,
+			},
+		},
+		{
+			Name: "soundcloud",
+			Patterns: []string{
+				`^https?://(?:www\.|m\.)?soundcloud\.com/(?P<user>[\w-]+)/(?P<slug>[\w-]+)`,
+			},
+			Templates: map[string]string{
+				renderHTML:    `<iframe width="100%" height="166" scrolling="no" frameborder="no" allow="autoplay" src="https://w.soundcloud.com/player/?url={{urlquery .URL}}"></iframe>`,
+				renderPelican: `{% soundcloud {{.URL}} %}`,
+			},
+		},
+		{
+			Name: "twitter",
+			Patterns: []string{
+				`^https?://(?:www\.|mobile\.)?(?:twitter|x)\.com/(?P<user>\w+)/status(?:es)?/(?P<id>\d+)`,
+			},
+			Templates: map[string]string{
+				renderHTML:   `<blockquote class="twitter-tweet"><a href="https://twitter.com/{{.User}}/status/{{.ID}}"></a></blockquote><script async src="https://platform.twitter.com/widgets.js" charset="utf-8"></script>`,
+				renderHugo:   `{{"{{<"}} tweet user="{{.User}}" id="{{.ID}}" {{">}}"}}`,
+				renderJekyll: `{% twitter https://twitter.com/{{.User}}/status/{{.ID}} %}`,
+			},
+		},
+		{
+			Name: "codepen",
+			Patterns: []string{
+				`^https?://codepen\.io/(?P<user>[\w-]+)/(?:pen|full|details|embed)/(?P<id>\w+)`,
+			},
+			Ratio: "4:3",
+			Templates: map[string]string{
+				renderHTML: `<iframe src="https://codepen.io/{{.User}}/embed/{{.ID}}?default-tab=result" style="{{.Style}}" loading="lazy" allowfullscreen></iframe>`,
+			},
+		},
+		{
+			// data-id of embed code is not a part of URL, use id=
+			Name: "speakerdeck",
+			Patterns: []string{
+				`^https?://speakerdeck\.com/(?P<user>[\w-]+)/(?P<slug>[\w-]+)`,
+			},
+			Ratio: "4:3",
+			Templates: map[string]string{
+				renderHTML:    `{{if .Params.id}}<iframe src="https://speakerdeck.com/player/{{urlquery .Params.id}}" style="{{.Style}}" allowfullscreen></iframe>{{else}}<a href="{{html .URL}}">{{.Slug}}</a>{{end}}`,
+				renderPelican: `{{if .Params.id}}{% speakerdeck {{.Params.id}} %}{{else}}<a href="{{html .URL}}">{{.Slug}}</a>{{end}}`,
+			},
+		},
+	}
+	for _, p := range builtins {
+		if err := RegisterEmbedProvider(p); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterEmbedProvider adds a provider used by maya:embed.
+// It is matched before providers registered earlier.
+// It is not allowed to register the same name twice.
+func RegisterEmbedProvider(p EmbedProvider) error {
+	if !actionRe.MatchString(p.Name) {
+		return fmt.Errorf("invalid provider name: %q", p.Name)
+	}
+	if _, ok := p.Templates[renderHTML]; !ok {
+		return fmt.Errorf("%s template required: %s", renderHTML, p.Name)
+	}
+	if _, err := parseRatio(p.Ratio); err != nil {
+		return fmt.Errorf("%s: %v", p.Name, err)
+	}
+
+	provider := &embedProvider{
+		EmbedProvider: p,
+		templates:     map[string]*template.Template{},
+		unsupported:   map[string][]string{},
+	}
+	for target, params := range p.Unsupported {
+		provider.unsupported[target] = params
+	}
+	for _, pattern := range p.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: %v", p.Name, err)
+		}
+		provider.patterns = append(provider.patterns, re)
+	}
+	for target, text := range p.Templates {
+		t, err := template.New(p.Name + "/" + target).Parse(text)
+		if err != nil {
+			return err
+		}
+		provider.templates[target] = t
+	}
+
+	embeds.mutex.Lock()
+	defer embeds.mutex.Unlock()
+	if embeds.find(p.Name) != nil {
+		return fmt.Errorf("embed provider already registered: %s", p.Name)
+	}
+	embeds.providers = append([]*embedProvider{provider}, embeds.providers...)
+	return nil
+}
+
+// SetEmbedTemplate replaces template of provider for render target.
+// target is html or metadata mode like hugo.
+// the new template is used with every param.
+func SetEmbedTemplate(provider, target, text string) error {
+	t, err := template.New(provider + "/" + target).Parse(text)
+	if err != nil {
+		return err
+	}
+	embeds.mutex.Lock()
+	defer embeds.mutex.Unlock()
+	p := embeds.find(provider)
+	if p == nil {
+		return fmt.Errorf("unknown embed provider: %s", provider)
+	}
+	p.templates[target] = t
+	delete(p.unsupported, target)
+	return nil
+}
+
+// EmbedProviders returns sorted names of embed providers.
+func EmbedProviders() []string {
+	embeds.mutex.RLock()
+	defer embeds.mutex.RUnlock()
+	names := []string{}
+	for _, p := range embeds.providers {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// find returns nil if provider does not exist. caller holds the lock.
+func (r *embedRegistry) find(name string) *embedProvider {
+	for _, p := range r.providers {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// match returns provider of url and values of named groups.
+func (r *embedRegistry) match(url string) (*embedProvider, map[string]string) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, p := range r.providers {
+		for _, re := range p.patterns {
+			m := re.FindStringSubmatch(url)
+			if m == nil {
+				continue
+			}
+			groups := map[string]string{}
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					groups[name] = m[i]
+				}
+			}
+			return p, groups
+		}
+	}
+	return nil, nil
+}
+
+// render executes template of target. html is used if target has no template
+// or a param which the template can not express is set.
+func (p *embedProvider) render(target string, data embedData) (string, error) {
+	embeds.mutex.RLock()
+	t, ok := p.templates[target]
+	for _, param := range p.unsupported[target] {
+		if data.Params[param] != "" {
+			ok = false
+		}
+	}
+	if !ok {
+		target = renderHTML
+		t = p.templates[renderHTML]
+	}
+	embeds.mutex.RUnlock()
+
+	ratio := p.Ratio
+	if r, ok := data.Params["ratio"]; ok {
+		ratio = r
+	}
+	padding, err := parseRatio(ratio)
+	if err != nil {
+		return "", err
+	}
+	if padding != "" {
+		data.Style = embedIframeStyle
+	}
+
+	if target != renderHTML {
+		if err := checkEmbedData(data); err != nil {
+			return "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	if target != renderHTML {
+		return buf.String(), nil
+	}
+
+	class := "maya-embed maya-embed-" + p.Name
+	if padding == "" {
+		return fmt.Sprintf(`<div class="%s">%s</div>`, class, buf.String()), nil
+	}
+	style := fmt.Sprintf("position:relative;width:100%%;height:0;padding-bottom:%s;overflow:hidden;", padding)
+	return fmt.Sprintf(`<div class="%s" style="%s">%s</div>`, class, style, buf.String()), nil
+}
+
+// embedUnsafeTexts end arguments of shortcodes and liquid tags.
+var embedUnsafeTexts = []string{`"`, "{{", "}}", "{%", "%}", "\n"}
+
+// checkEmbedData rejects values which break shortcodes and liquid tags,
+// templates of modes write them without escape.
+func checkEmbedData(data embedData) error {
+	values := map[string]string{
+		"url":  data.URL,
+		"id":   data.ID,
+		"user": data.User,
+		"slug": data.Slug,
+	}
+	keys := []string{"url", "id", "user", "slug"}
+	params := []string{}
+	for k, v := range data.Params {
+		params = append(params, k)
+		values["params."+k] = v
+	}
+	sort.Strings(params)
+	for _, k := range params {
+		keys = append(keys, "params."+k)
+	}
+	for _, k := range keys {
+		for _, text := range embedUnsafeTexts {
+			if strings.Contains(values[k], text) {
+				return fmt.Errorf("%s must not contain %q: %q", strings.TrimPrefix(k, "params."), text, values[k])
+			}
+		}
+	}
+	return nil
+}
+
+// parseRatio converts width:height to padding-bottom like 56.25%.
+func parseRatio(ratio string) (string, error) {
+	if ratio == "" {
+		return "", nil
+	}
+	tokens := strings.Split(ratio, ":")
+	if len(tokens) == 2 {
+		w, errW := strconv.ParseFloat(tokens[0], 64)
+		h, errH := strconv.ParseFloat(tokens[1], 64)
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			percent := strconv.FormatFloat(h/w*100, 'f', 2, 64)
+			percent = strings.TrimRight(strings.TrimRight(percent, "0"), ".")
+			return percent + "%", nil
+		}
+	}
+	return "", fmt.Errorf("invalid ratio: %q, expected width:height", ratio)
+}
@@ -0,0 +1,180 @@
+package maya
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbedRegistry_match(t *testing.T) {
+	cases := []struct {
+		url      string
+		provider string
+		groups   map[string]string
+	}{
+		{"https://www.youtube.com/watch?v=ESCv5qDuQIA", "youtube", map[string]string{"id": "ESCv5qDuQIA"}},
+		{"https://www.youtube.com/watch?feature=share&v=ESCv5qDuQIA&t=10", "youtube", map[string]string{"id": "ESCv5qDuQIA"}},
+		{"https://youtu.be/ESCv5qDuQIA", "youtube", map[string]string{"id": "ESCv5qDuQIA"}},
+		{"https://www.youtube.com/embed/ESCv5qDuQIA", "youtube", map[string]string{"id": "ESCv5qDuQIA"}},
+		{"https://www.youtube-nocookie.com/embed/ESCv5qDuQIA", "youtube", map[string]string{"id": "ESCv5qDuQIA"}},
+		{"https://vimeo.com/76979871", "vimeo", map[string]string{"id": "76979871"}},
+		{"https://player.vimeo.com/video/76979871", "vimeo", map[string]string{"id": "76979871"}},
+		{"https://gist.github.com/if1live/b23494b9e42ae89e6f28", "gist", map[string]string{"user": "if1live", "id": "b23494b9e42ae89e6f28"}},
+		{"https://gist.github.com/b23494b9e42ae89e6f28", "gist", map[string]string{"user": "", "id": "b23494b9e42ae89e6f28"}},
+		{"https://soundcloud.com/forss/flickermood", "soundcloud", map[string]string{"user": "forss", "slug": "flickermood"}},
+		{"https://twitter.com/golang/status/1234567890", "twitter", map[string]string{"user": "golang", "id": "1234567890"}},
+		{"https://x.com/golang/status/1234567890", "twitter", map[string]string{"user": "golang", "id": "1234567890"}},
+		{"https://codepen.io/team/pen/abcDEF", "codepen", map[string]string{"user": "team", "id": "abcDEF"}},
+		{"https://speakerdeck.com/user/my-talk", "speakerdeck", map[string]string{"user": "user", "slug": "my-talk"}},
+	}
+	for _, c := range cases {
+		p, groups := embeds.match(c.url)
+		if assert.NotNil(t, p, c.url) {
+			assert.Equal(t, c.provider, p.Name, c.url)
+			assert.Equal(t, c.groups, groups, c.url)
+		}
+	}
+
+	invalids := []string{
+		"https://example.com/watch?v=ESCv5qDuQIA",
+		"https://www.youtube.com/watch?v=short",
+		"https://vimeo.com/channels",
+		"javascript:alert(1)",
+	}
+	for _, url := range invalids {
+		p, _ := embeds.match(url)
+		assert.Nil(t, p, url)
+	}
+}
+
+func TestEmbedProvider_render(t *testing.T) {
+	cases := []struct {
+		url      string
+		params   map[string]string
+		expected string
+	}{
+		{
+			"https://youtu.be/ESCv5qDuQIA",
+			nil,
+			`<div class="maya-embed maya-embed-youtube" style="position:relative;width:100%;height:0;padding-bottom:56.25%;overflow:hidden;"><iframe src="https://www.youtube.com/embed/ESCv5qDuQIA" style="position:absolute;top:0;left:0;width:100%;height:100%;border:0;" allowfullscreen></iframe></div>`,
+		},
+		{
+			"https://vimeo.com/76979871",
+			map[string]string{"ratio": "4:3"},
+			`<div class="maya-embed maya-embed-vimeo" style="position:relative;width:100%;height:0;padding-bottom:75%;overflow:hidden;"><iframe src="https://player.vimeo.com/video/76979871" style="position:absolute;top:0;left:0;width:100%;height:100%;border:0;" allow="autoplay; fullscreen" allowfullscreen></iframe></div>`,
+		},
+		{
+			"https://gist.github.com/b23494b9e42ae89e6f28",
+			map[string]string{"file": "factorial.sh"},
+			`<div class="maya-embed maya-embed-gist"><script src="https://gist.github.com/b23494b9e42ae89e6f28.js?file=factorial.sh"></script></div>`,
+		},
+		{
+			"https://soundcloud.com/forss/flickermood",
+			nil,
+			`<div class="maya-embed maya-embed-soundcloud"><iframe width="100%" height="166" scrolling="no" frameborder="no" allow="autoplay" src="https://w.soundcloud.com/player/?url=https%3A%2F%2Fsoundcloud.com%2Fforss%2Fflickermood"></iframe></div>`,
+		},
+		{
+			"https://speakerdeck.com/user/my-talk",
+			nil,
+			`<div class="maya-embed maya-embed-speakerdeck" style="position:relative;width:100%;height:0;padding-bottom:75%;overflow:hidden;"><a href="https://speakerdeck.com/user/my-talk">my-talk</a></div>`,
+		},
+	}
+	for _, c := range cases {
+		actual, err := renderEmbed(&environment{}, c.url, c.params)
+		assert.Nil(t, err, c.url)
+		assert.Equal(t, c.expected, actual, c.url)
+	}
+
+	_, err := renderEmbed(&environment{}, "https://example.com", nil)
+	assert.NotNil(t, err)
+	_, err = renderEmbed(&environment{}, "https://youtu.be/ESCv5qDuQIA", map[string]string{"ratio": "wide"})
+	assert.NotNil(t, err)
+}
+
+func TestRegisterEmbedProvider(t *testing.T) {
+	p := EmbedProvider{
+		Name:      "example",
+		Patterns:  []string{`^https://example\.com/(?P<id>\d+)`},
+		Templates: map[string]string{renderHTML: `<a href="{{.URL}}">{{.ID}}</a>`},
+	}
+	assert.Nil(t, RegisterEmbedProvider(p))
+	defer unregisterEmbedProvider("example")
+	assert.NotNil(t, RegisterEmbedProvider(p))
+	assert.Contains(t, EmbedProviders(), "example")
+
+	actual, err := renderEmbed(&environment{}, "https://example.com/42", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `<div class="maya-embed maya-embed-example"><a href="https://example.com/42">42</a></div>`, actual)
+
+	invalids := []EmbedProvider{
+		{Name: "in valid", Templates: map[string]string{renderHTML: ""}},
+		{Name: "nohtml", Templates: map[string]string{}},
+		{Name: "pattern", Patterns: []string{"("}, Templates: map[string]string{renderHTML: ""}},
+		{Name: "template", Templates: map[string]string{renderHTML: "{{"}},
+		{Name: "ratio", Ratio: "16", Templates: map[string]string{renderHTML: ""}},
+	}
+	for _, p := range invalids {
+		assert.NotNil(t, RegisterEmbedProvider(p), p.Name)
+	}
+}
+
+func TestSetEmbedTemplate(t *testing.T) {
+	p, _ := embeds.match("https://vimeo.com/1")
+	original := p.templates[renderHTML]
+	defer func() { p.templates[renderHTML] = original }()
+
+	assert.Nil(t, SetEmbedTemplate("vimeo", renderHTML, `<a href="{{.URL}}">vimeo</a>`))
+	actual, err := renderEmbed(&environment{}, "https://vimeo.com/1", map[string]string{"ratio": ""})
+	assert.Nil(t, err)
+	assert.Equal(t, `<div class="maya-embed maya-embed-vimeo"><a href="https://vimeo.com/1">vimeo</a></div>`, actual)
+
+	// params unsupported by the builtin template are used by a new template
+	youtube, _ := embeds.match("https://youtu.be/ESCv5qDuQIA")
+	hugo := youtube.templates[renderHugo]
+	unsupported := youtube.unsupported[renderHugo]
+	defer func() {
+		youtube.templates[renderHugo] = hugo
+		youtube.unsupported[renderHugo] = unsupported
+	}()
+	params := map[string]string{"nocookie": "1"}
+	actual, err = renderEmbed(&environment{mode: ModeHugo}, "https://youtu.be/ESCv5qDuQIA", params)
+	assert.Nil(t, err)
+	assert.Contains(t, actual, "youtube-nocookie.com")
+	assert.Nil(t, SetEmbedTemplate("youtube", renderHugo, `{{"{{<"}} youtube {{.ID}}{{if .Params.nocookie}} nocookie{{end}} {{">}}"}}`))
+	actual, err = renderEmbed(&environment{mode: ModeHugo}, "https://youtu.be/ESCv5qDuQIA", params)
+	assert.Nil(t, err)
+	assert.Equal(t, "{{< youtube ESCv5qDuQIA nocookie >}}", actual)
+
+	assert.NotNil(t, SetEmbedTemplate("not-exist", renderHTML, ""))
+	assert.NotNil(t, SetEmbedTemplate("vimeo", renderHTML, "{{"))
+}
+
+func unregisterEmbedProvider(name string) {
+	embeds.mutex.Lock()
+	defer embeds.mutex.Unlock()
+	for i, p := range embeds.providers {
+		if p.Name == name {
+			embeds.providers = append(embeds.providers[:i], embeds.providers[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestParseRatio(t *testing.T) {
+	cases := map[string]string{
+		"":     "",
+		"16:9": "56.25%",
+		"4:3":  "75%",
+		"1:1":  "100%",
+		"21:9": "42.86%",
+	}
+	for ratio, expected := range cases {
+		actual, err := parseRatio(ratio)
+		assert.Nil(t, err, ratio)
+		assert.Equal(t, expected, actual, ratio)
+	}
+	for _, ratio := range []string{"16", "a:b", "0:1", "16:9:1"} {
+		_, err := parseRatio(ratio)
+		assert.NotNil(t, err, ratio)
+	}
+}
@@ -0,0 +1,62 @@
+package maya
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Error is an error occurred while processing a part of an article.
+type Error struct {
+	// File is the source file. empty if unknown.
+	File string
+	// Line is 1-based line number. 0 if unknown.
+	Line int
+	// Column is 1-based column number. 0 if unknown.
+	Column int
+	// Block is 1-based index of maya block. 0 if not a block.
+	Block  int
+	Action string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	var buf bytes.Buffer
+	if e.File != "" {
+		buf.WriteString(e.File)
+	} else {
+		buf.WriteString("<input>")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&buf, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&buf, ":%d", e.Column)
+		}
+	}
+	buf.WriteString(": ")
+	if e.Block > 0 {
+		fmt.Fprintf(&buf, "block %d (maya:%s): ", e.Block, e.Action)
+	}
+	buf.WriteString(e.Err.Error())
+	return buf.String()
+}
+
+// ErrorList is a list of errors.
+// It is returned when every broken block should be reported at once.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Err returns nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
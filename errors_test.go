@@ -0,0 +1,42 @@
+package maya
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_Error(t *testing.T) {
+	cases := []struct {
+		err      *Error
+		expected string
+	}{
+		{
+			&Error{File: "a.md", Line: 3, Block: 2, Action: "view", Err: errors.New("fail")},
+			"a.md:3: block 2 (maya:view): fail",
+		},
+		{
+			&Error{File: "a.md", Line: 3, Column: 1, Block: 2, Action: "view", Err: errors.New("fail")},
+			"a.md:3:1: block 2 (maya:view): fail",
+		},
+		{
+			&Error{File: "a.md", Err: errors.New("fail")},
+			"a.md: fail",
+		},
+		{
+			&Error{Line: 1, Block: 1, Action: "execute", Err: errors.New("fail")},
+			"<input>:1: block 1 (maya:execute): fail",
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, c.err.Error())
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	assert.Nil(t, ErrorList{}.Err())
+
+	list := ErrorList{errors.New("foo"), errors.New("bar")}
+	assert.Equal(t, "foo\nbar", list.Err().Error())
+}
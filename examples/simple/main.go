@@ -16,7 +16,13 @@ id=b23494b9e42ae89e6f28
 file=factorial.sh
 ~~~
 gist sample end`
-	article := maya.NewArticle(intext, "empty")
-	outtext := article.OutputString()
+	article, err := maya.NewArticle(intext, "empty")
+	if err != nil {
+		panic(err)
+	}
+	outtext, err := article.OutputString()
+	if err != nil {
+		panic(err)
+	}
 	fmt.Println(outtext)
 }
@@ -1,6 +1,9 @@
 package maya
 
 import (
+	"bytes"
+	"fmt"
+	"html"
 	"strings"
 )
 
@@ -15,29 +18,59 @@ type formatter interface {
 	format(lines []string, args ...string) string
 }
 
-func format(format string, text string, args ...string) string {
-	f := newFormatter(format)
+func format(format string, text string, args ...string) (string, error) {
+	f, err := newFormatter(format)
+	if err != nil {
+		return "", err
+	}
 	lines := strings.Split(text, "\n")
-	return f.format(lines, args...)
+	return f.format(lines, args...), nil
 }
 
-func newFormatter(format string) formatter {
+func newFormatter(format string) (formatter, error) {
 	switch format {
 	case formatCode:
-		return &codeFormatter{}
+		return &codeFormatter{}, nil
 	case formatBlockquote:
-		return &blockquoteFormatter{}
+		return &blockquoteFormatter{}, nil
 	case formatBold:
-		return &boldFormatter{}
+		return &boldFormatter{}, nil
 	case formatText:
-		return &textFormatter{}
+		return &textFormatter{}, nil
 	default:
-		msg := "unknown format : " + format
-		panic(msg)
+		return nil, fmt.Errorf("unknown format: %s", format)
 	}
 }
 
-type codeFormatter struct{}
+const (
+	renderMarkdown = "markdown"
+	renderHugo     = "hugo"
+	renderPelican  = "pelican"
+	renderHTML     = "html"
+	renderJekyll   = "jekyll"
+	renderHexo     = "hexo"
+	renderZola     = "zola"
+)
+
+// codeFormatter renders fenced code block.
+// line numbers and highlight are rendered as fence attributes of render target.
+type codeFormatter struct {
+	// render is markdown/hugo/pelican/html/jekyll/hexo/zola. empty is markdown.
+	render string
+	// numbers are line numbers of lines. nil hides line numbers.
+	// zero is a line which has no number, like elision marker.
+	numbers []int
+	// highlight is 1-based indexes of lines to highlight.
+	highlight []int
+}
+
+func isRenderTarget(render string) bool {
+	switch render {
+	case "", renderMarkdown, renderHugo, renderPelican, renderHTML, renderJekyll, renderHexo, renderZola:
+		return true
+	}
+	return false
+}
 
 func (f *codeFormatter) getLanguage(args ...string) string {
 	lang := ""
@@ -63,12 +96,6 @@ func (f *codeFormatter) convertLanguage(lang string) string {
 
 func (f *codeFormatter) format(lines []string, args ...string) string {
 	lang := f.getLanguage(args...)
-	headLine := "```" + lang
-	tailLine := "```"
-
-	if len(lines) == 0 {
-		return strings.Join([]string{headLine, tailLine}, "\n")
-	}
 
 	isBlankLine := func(line string) bool {
 		return strings.Trim(line, "\t\r ") == ""
@@ -90,13 +117,214 @@ func (f *codeFormatter) format(lines []string, args ...string) string {
 		}
 	}
 
+	// line numbers follow the trimmed lines
+	trimmed := *f
+	if len(f.numbers) == len(lines) && len(lines) > 0 {
+		trimmed.numbers = f.numbers[startIdx : endIdx+1]
+	}
+	if len(lines) > 0 {
+		lines = lines[startIdx : endIdx+1]
+	}
+
+	if f.render == renderHTML {
+		return trimmed.formatHTML(lines, lang)
+	}
+	// line numbers of jekyll always start at 1
+	if f.numbers != nil && (f.render == "" || f.render == renderMarkdown || f.render == renderJekyll) {
+		lines = trimmed.prefixNumbers(lines)
+	}
+
+	begin, end := "```"+trimmed.fenceInfo(lang), "```"
+	if tag := trimmed.blockTag(lang); tag != "" {
+		begin, end = "{% "+tag+" %}", "{% end"+strings.Fields(tag)[0]+" %}"
+	}
+
 	newLines := []string{}
-	newLines = append(newLines, headLine)
-	newLines = append(newLines, lines[startIdx:endIdx+1]...)
-	newLines = append(newLines, tailLine)
+	newLines = append(newLines, begin)
+	newLines = append(newLines, lines...)
+	newLines = append(newLines, end)
 	return strings.Join(newLines, "\n")
 }
 
+// blockTag returns liquid style tag of render target which is used instead of fence.
+// empty if fence can express line numbers and highlight.
+func (f *codeFormatter) blockTag(lang string) string {
+	switch f.render {
+	case renderJekyll:
+		if len(f.highlight) == 0 {
+			return ""
+		}
+		if lang == "" {
+			lang = "text"
+		}
+		marks := []string{}
+		for _, i := range f.highlight {
+			marks = append(marks, fmt.Sprintf("%d", i))
+		}
+		return fmt.Sprintf("highlight %s mark_lines=%q", lang, strings.Join(marks, " "))
+
+	case renderHexo:
+		if f.numbers == nil && len(f.highlight) == 0 {
+			return ""
+		}
+		attrs := []string{"codeblock"}
+		if lang != "" {
+			attrs = append(attrs, "lang:"+lang)
+		}
+		if f.numbers != nil {
+			attrs = append(attrs, "line_number:true", fmt.Sprintf("first_line:%d", f.firstNumber()))
+		} else {
+			attrs = append(attrs, "line_number:false")
+		}
+		if len(f.highlight) > 0 {
+			attrs = append(attrs, "mark:"+formatRanges(f.highlight, ","))
+		}
+		return strings.Join(attrs, " ")
+	}
+	return ""
+}
+
+// firstNumber returns line number of the first line. zero if no line numbers.
+func (f *codeFormatter) firstNumber() int {
+	if len(f.numbers) > 0 {
+		return f.numbers[0]
+	}
+	return 0
+}
+
+// formatRanges writes indexes as ranges like 1-3 joined by sep.
+func formatRanges(indexes []int, sep string) string {
+	ranges := []string{}
+	for _, r := range compressRanges(indexes) {
+		if r.first == r.last {
+			ranges = append(ranges, fmt.Sprintf("%d", r.first))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", r.first, r.last))
+		}
+	}
+	return strings.Join(ranges, sep)
+}
+
+// fenceInfo returns info string of fence with attributes of render target.
+func (f *codeFormatter) fenceInfo(lang string) string {
+	first := f.firstNumber()
+
+	switch f.render {
+	case renderHugo:
+		attrs := []string{}
+		if f.numbers != nil {
+			attrs = append(attrs, "linenos=table", fmt.Sprintf("linenostart=%d", first))
+		}
+		if len(f.highlight) > 0 {
+			ranges := []string{}
+			for _, r := range compressRanges(f.highlight) {
+				if r.first == r.last {
+					ranges = append(ranges, fmt.Sprintf("%d", r.first))
+				} else {
+					ranges = append(ranges, fmt.Sprintf("%q", fmt.Sprintf("%d-%d", r.first, r.last)))
+				}
+			}
+			attrs = append(attrs, "hl_lines=["+strings.Join(ranges, ",")+"]")
+		}
+		if len(attrs) == 0 {
+			return lang
+		}
+		if lang == "" {
+			lang = "text"
+		}
+		return lang + " {" + strings.Join(attrs, ",") + "}"
+
+	case renderPelican:
+		// attribute list of Python-Markdown fenced_code
+		attrs := []string{}
+		if f.numbers != nil {
+			attrs = append(attrs, fmt.Sprintf("linenums=%q", fmt.Sprintf("%d", first)))
+		}
+		if len(f.highlight) > 0 {
+			indexes := []string{}
+			for _, i := range f.highlight {
+				indexes = append(indexes, fmt.Sprintf("%d", i))
+			}
+			attrs = append(attrs, fmt.Sprintf("hl_lines=%q", strings.Join(indexes, " ")))
+		}
+		if len(attrs) == 0 {
+			return lang
+		}
+		if lang != "" {
+			attrs = append([]string{"." + lang}, attrs...)
+		}
+		return "{ " + strings.Join(attrs, " ") + " }"
+
+	case renderZola:
+		attrs := []string{}
+		if f.numbers != nil {
+			attrs = append(attrs, "linenos", fmt.Sprintf("linenostart=%d", first))
+		}
+		if len(f.highlight) > 0 {
+			attrs = append(attrs, "hl_lines="+formatRanges(f.highlight, " "))
+		}
+		if len(attrs) == 0 {
+			return lang
+		}
+		if lang == "" {
+			lang = "text"
+		}
+		return lang + "," + strings.Join(attrs, ",")
+	}
+	return lang
+}
+
+// prefixNumbers writes line numbers in front of lines.
+func (f *codeFormatter) prefixNumbers(lines []string) []string {
+	width := 1
+	for _, n := range f.numbers {
+		if w := len(fmt.Sprintf("%d", n)); w > width {
+			width = w
+		}
+	}
+	numbered := make([]string, len(lines))
+	for i, line := range lines {
+		num := ""
+		if i < len(f.numbers) && f.numbers[i] != 0 {
+			num = fmt.Sprintf("%d", f.numbers[i])
+		}
+		numbered[i] = strings.TrimRight(fmt.Sprintf("%*s  %s", width, num, line), " ")
+	}
+	return numbered
+}
+
+func (f *codeFormatter) formatHTML(lines []string, lang string) string {
+	highlighted := map[int]bool{}
+	for _, i := range f.highlight {
+		highlighted[i] = true
+	}
+
+	var buf bytes.Buffer
+	if lang != "" {
+		fmt.Fprintf(&buf, `<pre><code class="language-%s">`, html.EscapeString(lang))
+	} else {
+		buf.WriteString("<pre><code>")
+	}
+	for i, line := range lines {
+		class := "line"
+		if highlighted[i+1] {
+			class += " hl"
+		}
+		fmt.Fprintf(&buf, `<span class="%s">`, class)
+		if f.numbers != nil {
+			num := ""
+			if i < len(f.numbers) && f.numbers[i] != 0 {
+				num = fmt.Sprintf("%d", f.numbers[i])
+			}
+			fmt.Fprintf(&buf, `<span class="ln">%s</span>`, num)
+		}
+		buf.WriteString(html.EscapeString(line))
+		buf.WriteString("</span>\n")
+	}
+	buf.WriteString("</code></pre>")
+	return buf.String()
+}
+
 type blockquoteFormatter struct{}
 
 func (f *blockquoteFormatter) format(lines []string, args ...string) string {
@@ -1,6 +1,7 @@
 package maya
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -103,3 +104,87 @@ func Test_boldFormatter_format(t *testing.T) {
 		assert.Equal(t, c.output, f.format(c.lines, c.args...))
 	}
 }
+
+func Test_newFormatter_unknown(t *testing.T) {
+	_, err := newFormatter("unknown")
+	assert.NotNil(t, err)
+}
+
+func Test_codeFormatter_format_attrs(t *testing.T) {
+	lines := []string{"", "a := 1", "// ...", "b := 2", ""}
+	numbers := []int{9, 10, 0, 20, 21}
+	cases := []struct {
+		f      codeFormatter
+		output string
+	}{
+		{
+			codeFormatter{numbers: numbers},
+			"```go\n10  a := 1\n    // ...\n20  b := 2\n```",
+		},
+		{
+			codeFormatter{render: renderHugo, numbers: numbers, highlight: []int{1, 2, 3}},
+			"```go {linenos=table,linenostart=10,hl_lines=[\"1-3\"]}\na := 1\n// ...\nb := 2\n```",
+		},
+		{
+			codeFormatter{render: renderHugo, highlight: []int{1, 3}},
+			"```go {hl_lines=[1,3]}\na := 1\n// ...\nb := 2\n```",
+		},
+		{
+			codeFormatter{render: renderHugo},
+			"```go\na := 1\n// ...\nb := 2\n```",
+		},
+		{
+			codeFormatter{render: renderPelican, numbers: numbers, highlight: []int{1, 3}},
+			"```{ .go linenums=\"10\" hl_lines=\"1 3\" }\na := 1\n// ...\nb := 2\n```",
+		},
+		{
+			codeFormatter{render: renderHTML, numbers: numbers, highlight: []int{3}},
+			strings.Join([]string{
+				`<pre><code class="language-go"><span class="line"><span class="ln">10</span>a := 1</span>`,
+				`<span class="line"><span class="ln"></span>// ...</span>`,
+				`<span class="line hl"><span class="ln">20</span>b := 2</span>`,
+				`</code></pre>`,
+			}, "\n"),
+		},
+		{
+			codeFormatter{render: renderJekyll, numbers: numbers, highlight: []int{1, 3}},
+			"{% highlight go mark_lines=\"1 3\" %}\n10  a := 1\n    // ...\n20  b := 2\n{% endhighlight %}",
+		},
+		{
+			codeFormatter{render: renderJekyll},
+			"```go\na := 1\n// ...\nb := 2\n```",
+		},
+		{
+			codeFormatter{render: renderHexo, numbers: numbers, highlight: []int{1, 2, 3}},
+			"{% codeblock lang:go line_number:true first_line:10 mark:1-3 %}\na := 1\n// ...\nb := 2\n{% endcodeblock %}",
+		},
+		{
+			codeFormatter{render: renderHexo, highlight: []int{1, 3}},
+			"{% codeblock lang:go line_number:false mark:1,3 %}\na := 1\n// ...\nb := 2\n{% endcodeblock %}",
+		},
+		{
+			codeFormatter{render: renderHexo},
+			"```go\na := 1\n// ...\nb := 2\n```",
+		},
+		{
+			codeFormatter{render: renderZola, numbers: numbers, highlight: []int{1, 3}},
+			"```go,linenos,linenostart=10,hl_lines=1 3\na := 1\n// ...\nb := 2\n```",
+		},
+		{
+			codeFormatter{render: renderZola, highlight: []int{1, 2, 3}},
+			"```go,hl_lines=1-3\na := 1\n// ...\nb := 2\n```",
+		},
+		{
+			codeFormatter{render: renderHTML},
+			"<pre><code class=\"language-go\"><span class=\"line\">a := 1</span>\n<span class=\"line\">// ...</span>\n<span class=\"line\">b := 2</span>\n</code></pre>",
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.output, c.f.format(lines, "go"), c.f.render)
+	}
+}
+
+func Test_codeFormatter_format_htmlEscape(t *testing.T) {
+	f := codeFormatter{render: renderHTML}
+	assert.Equal(t, "<pre><code><span class=\"line\">&lt;a href=&#34;x&#34;&gt;</span>\n</code></pre>", f.format([]string{`<a href="x">`}))
+}
@@ -0,0 +1,174 @@
+package maya
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Gist is a gist with its files.
+type Gist struct {
+	ID    string
+	Files []GistFile
+}
+
+// GistFile is a file of gist.
+type GistFile struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// GistFetcher gets gists for maya:gist with inline=true.
+// Replace it to serve gists from a mirror in tests or offline builds.
+type GistFetcher interface {
+	FetchGist(ctx context.Context, id string) (*Gist, error)
+}
+
+// file returns the file of gist. name can be empty if gist has one file.
+func (g *Gist) file(name string) (*GistFile, error) {
+	if name == "" && len(g.Files) == 1 {
+		return &g.Files[0], nil
+	}
+	names := []string{}
+	for i, f := range g.Files {
+		if f.Name == name {
+			return &g.Files[i], nil
+		}
+		names = append(names, f.Name)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("gist %s has %d files, file required: %s", g.ID, len(g.Files), strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("gist %s has no file %s: %s", g.ID, name, strings.Join(names, ", "))
+}
+
+// HTTPGistFetcher gets gists from GitHub API.
+type HTTPGistFetcher struct {
+	// Client is http.DefaultClient if nil.
+	Client *http.Client
+	// BaseURL is https://api.github.com if empty.
+	BaseURL string
+}
+
+func (f *HTTPGistFetcher) get(ctx context.Context, url string) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return data, nil
+}
+
+// checkGistID rejects ids which would point outside of the gist.
+func checkGistID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return fmt.Errorf("invalid gist id: %q", id)
+	}
+	return nil
+}
+
+func (f *HTTPGistFetcher) FetchGist(ctx context.Context, id string) (*Gist, error) {
+	if err := checkGistID(id); err != nil {
+		return nil, err
+	}
+	baseURL := f.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.github.com"
+	}
+	data, err := f.get(ctx, strings.TrimRight(baseURL, "/")+"/gists/"+id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := struct {
+		Files map[string]struct {
+			Filename  string `json:"filename"`
+			Language  string `json:"language"`
+			Content   string `json:"content"`
+			Truncated bool   `json:"truncated"`
+			RawURL    string `json:"raw_url"`
+		} `json:"files"`
+	}{}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("gist %s: %v", id, err)
+	}
+
+	gist := &Gist{ID: id}
+	for _, file := range resp.Files {
+		content := file.Content
+		if file.Truncated {
+			// large file is not included in response
+			raw, err := f.get(ctx, file.RawURL)
+			if err != nil {
+				return nil, err
+			}
+			content = string(raw)
+		}
+		gist.Files = append(gist.Files, GistFile{
+			Name:     file.Filename,
+			Language: file.Language,
+			Content:  content,
+		})
+	}
+	sort.Slice(gist.Files, func(i, j int) bool {
+		return gist.Files[i].Name < gist.Files[j].Name
+	})
+	return gist, nil
+}
+
+// DirGistFetcher reads gists from Dir/<id>/<file>.
+type DirGistFetcher struct {
+	Dir string
+}
+
+func (f *DirGistFetcher) FetchGist(ctx context.Context, id string) (*Gist, error) {
+	if err := checkGistID(id); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(f.Dir, id)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("gist %s not found in %s", id, f.Dir)
+		}
+		return nil, err
+	}
+
+	gist := &Gist{ID: id}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		gist.Files = append(gist.Files, GistFile{
+			Name:    info.Name(),
+			Content: string(data),
+		})
+	}
+	return gist, nil
+}
@@ -0,0 +1,87 @@
+package maya
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGist_file(t *testing.T) {
+	gist := &Gist{ID: "1", Files: []GistFile{{Name: "a.sh"}, {Name: "b.py"}}}
+	file, err := gist.file("b.py")
+	assert.Nil(t, err)
+	assert.Equal(t, "b.py", file.Name)
+
+	_, err = gist.file("")
+	assert.NotNil(t, err)
+	_, err = gist.file("c.go")
+	assert.NotNil(t, err)
+
+	single := &Gist{ID: "2", Files: []GistFile{{Name: "a.sh"}}}
+	file, err = single.file("")
+	assert.Nil(t, err)
+	assert.Equal(t, "a.sh", file.Name)
+}
+
+func TestHTTPGistFetcher(t *testing.T) {
+	var server *httptest.Server
+	requests := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/gists/abc":
+			fmt.Fprintf(w, `{"files": {
+				"b.py": {"filename": "b.py", "language": "Python", "content": "print(1)\n"},
+				"a.sh": {"filename": "a.sh", "language": "Shell", "truncated": true, "raw_url": "%s/raw/a.sh"}
+			}}`, server.URL)
+		case "/raw/a.sh":
+			fmt.Fprint(w, "echo large\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := &HTTPGistFetcher{BaseURL: server.URL}
+	gist, err := f.FetchGist(context.Background(), "abc")
+	assert.Nil(t, err)
+	assert.Equal(t, &Gist{ID: "abc", Files: []GistFile{
+		{Name: "a.sh", Language: "Shell", Content: "echo large\n"},
+		{Name: "b.py", Language: "Python", Content: "print(1)\n"},
+	}}, gist)
+
+	_, err = f.FetchGist(context.Background(), "not-exist")
+	assert.NotNil(t, err)
+
+	// invalid ids are not requested
+	requests = 0
+	for _, id := range []string{"", "..", "../users/if1live", `abc\..`} {
+		_, err := f.FetchGist(context.Background(), id)
+		assert.NotNil(t, err, id)
+	}
+	assert.Equal(t, 0, requests)
+}
+
+func TestDirGistFetcher(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "abc"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "abc", "a.sh"), []byte("echo 1\n"), 0644)
+
+	f := &DirGistFetcher{dir}
+	gist, err := f.FetchGist(context.Background(), "abc")
+	assert.Nil(t, err)
+	assert.Equal(t, &Gist{ID: "abc", Files: []GistFile{{Name: "a.sh", Content: "echo 1\n"}}}, gist)
+
+	for _, id := range []string{"not-exist", "", "..", "../abc"} {
+		_, err := f.FetchGist(context.Background(), id)
+		assert.NotNil(t, err, id)
+	}
+}
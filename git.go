@@ -0,0 +1,101 @@
+package maya
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runGit runs git in dir and returns stdout.
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// verifyRevision checks that rev names an object in the repository of dir.
+// revisions are written by the document, so options like --output are rejected.
+func verifyRevision(ctx context.Context, dir, rev string) error {
+	if strings.HasPrefix(rev, "-") {
+		return fmt.Errorf("invalid revision: %q", rev)
+	}
+	_, err := runGit(ctx, dir, "rev-parse", "--verify", "--quiet", "--end-of-options", rev)
+	if err != nil {
+		return fmt.Errorf("unknown revision: %q", rev)
+	}
+	return nil
+}
+
+// gitShow returns content of file at revision.
+// path is absolute path of the file in a working tree.
+func gitShow(ctx context.Context, path, rev string) ([]byte, error) {
+	dir, name := filepath.Split(path)
+	if err := verifyRevision(ctx, dir, rev); err != nil {
+		return nil, err
+	}
+	return runGit(ctx, dir, "show", "--end-of-options", rev+":./"+name)
+}
+
+// gitDiff returns unified diff of file between revisions.
+// if to is empty, from is compared with the working tree.
+func gitDiff(ctx context.Context, path, from, to string, contextLines int) (string, error) {
+	dir, name := filepath.Split(path)
+	revs := []string{from}
+	if to != "" {
+		revs = append(revs, to)
+	}
+	for _, rev := range revs {
+		if err := verifyRevision(ctx, dir, rev); err != nil {
+			return "", err
+		}
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff", fmt.Sprintf("-U%d", contextLines), "--end-of-options"}
+	args = append(args, revs...)
+	args = append(args, "--", name)
+	out, err := runGit(ctx, dir, args...)
+	if err != nil {
+		return "", err
+	}
+
+	// drop `diff --git` and `index` headers. ---, +++ and hunks are kept
+	lines := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if len(lines) == 0 && !strings.HasPrefix(line, "---") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n"), nil
+}
+
+// repoPath returns absolute path of file referenced by view or diff block.
+// if repo is set, file is relative to it. otherwise file is resolved from the document.
+func (env *environment) repoPath(repo, file string) (string, error) {
+	if repo == "" {
+		return env.resolve(file)
+	}
+	dir := repo
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(env.dir(), dir)
+	}
+	path := filepath.Join(dir, file)
+	if err := env.checkRoot(path); err != nil {
+		return "", fmt.Errorf("%s: %v", file, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,102 @@
+package maya
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newGitRepo creates a repository with hello.py tagged v1 and v2.
+func newGitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required")
+	}
+	dir, _ := ioutil.TempDir("", "maya")
+	ctx := context.Background()
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=maya", "-c", "user.email=maya@example.com"}, args...)
+		if _, err := runGit(ctx, dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit := func(text, tag string) {
+		ioutil.WriteFile(filepath.Join(dir, "src", "hello.py"), []byte(text), 0644)
+		git("add", "-A")
+		git("commit", "-q", "-m", tag)
+		git("tag", tag)
+	}
+
+	git("init", "-q")
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	commit("print('hello')\n", "v1")
+	commit("print('hello')\nprint('world')\n", "v2")
+	ioutil.WriteFile(filepath.Join(dir, "src", "hello.py"), []byte("print('working tree')\n"), 0644)
+	return dir
+}
+
+func TestGitShow(t *testing.T) {
+	dir := newGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "src", "hello.py")
+	data, err := gitShow(context.Background(), path, "v1")
+	assert.Nil(t, err)
+	assert.Equal(t, "print('hello')\n", string(data))
+
+	_, err = gitShow(context.Background(), path, "v3")
+	assert.NotNil(t, err)
+	_, err = gitShow(context.Background(), filepath.Join(dir, "src", "not-exist.py"), "v1")
+	assert.NotNil(t, err)
+}
+
+func TestGitDiff(t *testing.T) {
+	dir := newGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "src", "hello.py")
+	diff, err := gitDiff(context.Background(), path, "v1", "v2", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "--- a/src/hello.py\n+++ b/src/hello.py\n@@ -1 +1,2 @@\n print('hello')\n+print('world')", diff)
+
+	diff, err = gitDiff(context.Background(), path, "v2", "", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "--- a/src/hello.py\n+++ b/src/hello.py\n@@ -1,2 +1 @@\n-print('hello')\n-print('world')\n+print('working tree')", diff)
+
+	diff, err = gitDiff(context.Background(), path, "v1", "v1", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "", diff)
+}
+
+func TestGit_revisionOptions(t *testing.T) {
+	dir := newGitRepo(t)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	path := filepath.Join(dir, "src", "hello.py")
+	outside, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(outside)
+	secret := filepath.Join(outside, "secret.txt")
+	ioutil.WriteFile(secret, []byte("secret\n"), 0644)
+
+	// options given as revision do not write files
+	pwned := filepath.Join(outside, "pwned.txt")
+	_, err := gitDiff(ctx, path, "--output="+pwned, "", 3)
+	assert.NotNil(t, err)
+	_, err = os.Stat(pwned)
+	assert.True(t, os.IsNotExist(err))
+
+	// nor read files outside the repository
+	diff, err := gitDiff(ctx, path, "--no-index", secret, 3)
+	assert.NotNil(t, err)
+	assert.NotContains(t, diff, "secret")
+
+	_, err = gitShow(ctx, path, "--output="+pwned)
+	assert.NotNil(t, err)
+	_, err = gitDiff(ctx, path, "v1", secret, 3)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,123 @@
+package maya
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// goSymbol selects a declaration of Go source.
+// Kind is func/type/method/const and Name is like Foo or Type.Foo for method.
+type goSymbol struct {
+	Kind string
+	Name string
+	// Doc includes the doc comment of the declaration.
+	Doc bool
+}
+
+func (s goSymbol) String() string {
+	return s.Kind + " " + s.Name
+}
+
+// lineRange returns 1-based first and last line of the declaration.
+func (s goSymbol) lineRange(filename string, src []byte) (int, int, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	type found struct {
+		doc        *ast.CommentGroup
+		start, end token.Pos
+	}
+	matches := []found{}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !s.matchFunc(d) {
+				continue
+			}
+			matches = append(matches, found{d.Doc, d.Pos(), d.End()})
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				doc, ok := s.matchSpec(d.Tok, spec)
+				if !ok {
+					continue
+				}
+				if !d.Lparen.IsValid() {
+					// single declaration like `type Foo struct{}`
+					matches = append(matches, found{d.Doc, d.Pos(), d.End()})
+				} else {
+					matches = append(matches, found{doc, spec.Pos(), spec.End()})
+				}
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return 0, 0, fmt.Errorf("%s not found in %s", s, filename)
+	case 1:
+	default:
+		return 0, 0, fmt.Errorf("%s is declared %d times in %s", s, len(matches), filename)
+	}
+
+	m := matches[0]
+	start := m.start
+	if s.Doc && m.doc != nil {
+		start = m.doc.Pos()
+	}
+	return fset.Position(start).Line, fset.Position(m.end).Line, nil
+}
+
+func (s goSymbol) matchFunc(d *ast.FuncDecl) bool {
+	switch s.Kind {
+	case "func":
+		return d.Recv == nil && d.Name.Name == s.Name
+	case "method":
+		if d.Recv == nil || len(d.Recv.List) == 0 {
+			return false
+		}
+		return receiverName(d.Recv.List[0].Type)+"."+d.Name.Name == s.Name
+	}
+	return false
+}
+
+func (s goSymbol) matchSpec(tok token.Token, spec ast.Spec) (*ast.CommentGroup, bool) {
+	switch sp := spec.(type) {
+	case *ast.TypeSpec:
+		return sp.Doc, s.Kind == "type" && sp.Name.Name == s.Name
+	case *ast.ValueSpec:
+		if s.Kind != "const" || tok != token.CONST {
+			return nil, false
+		}
+		for _, name := range sp.Names {
+			if name.Name == s.Name {
+				return sp.Doc, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// receiverName returns type name of receiver without pointer and type parameters.
+func receiverName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return receiverName(e.X)
+	case *ast.IndexExpr:
+		return receiverName(e.X)
+	case *ast.ParenExpr:
+		return receiverName(e.X)
+	}
+	if x, ok := indexListReceiver(expr); ok {
+		return receiverName(x)
+	}
+	return ""
+}
@@ -0,0 +1,11 @@
+//go:build !go1.18
+// +build !go1.18
+
+package maya
+
+import "go/ast"
+
+// indexListReceiver is used with go 1.18 or later, which parses many type parameters.
+func indexListReceiver(expr ast.Expr) (ast.Expr, bool) {
+	return nil, false
+}
@@ -0,0 +1,15 @@
+//go:build go1.18
+// +build go1.18
+
+package maya
+
+import "go/ast"
+
+// indexListReceiver returns receiver type without type parameters like Set of Set[K, V].
+// ast.IndexListExpr exists since go 1.18.
+func indexListReceiver(expr ast.Expr) (ast.Expr, bool) {
+	if e, ok := expr.(*ast.IndexListExpr); ok {
+		return e.X, true
+	}
+	return nil, false
+}
@@ -0,0 +1,39 @@
+//go:build go1.18
+// +build go1.18
+
+package maya
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const goGenericSource = `package demo
+
+type Set[K comparable, V any] map[K]V
+
+func (s *Set[K, V]) Len() int { return len(*s) }
+
+type Box[T any] struct{ v T }
+
+func (b Box[T]) Get() T { return b.v }
+`
+
+func TestGoSymbol_lineRange_generic(t *testing.T) {
+	lines := strings.Split(goGenericSource, "\n")
+	cases := []struct {
+		symbol   goSymbol
+		expected string
+	}{
+		{goSymbol{"method", "Set.Len", true}, "func (s *Set[K, V]) Len() int { return len(*s) }"},
+		{goSymbol{"method", "Box.Get", true}, "func (b Box[T]) Get() T { return b.v }"},
+	}
+	for _, c := range cases {
+		first, last, err := c.symbol.lineRange("demo.go", []byte(goGenericSource))
+		if assert.Nil(t, err, c.symbol.String()) {
+			assert.Equal(t, []string{c.expected}, lines[first-1:last], c.symbol.String())
+		}
+	}
+}
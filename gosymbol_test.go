@@ -0,0 +1,97 @@
+package maya
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const goSymbolSource = `package demo
+
+// Answer is the answer.
+const Answer = 42
+
+const (
+	// Red is red.
+	Red = iota
+	Green // Green is green.
+)
+
+// Point is a point.
+type Point struct {
+	X, Y int
+}
+
+type (
+	// Celsius is temperature.
+	Celsius float64
+	Fahrenheit float64
+)
+
+// Add returns sum of two points.
+func Add(a, b Point) Point {
+	return Point{a.X + b.X, a.Y + b.Y}
+}
+
+// Scale multiplies a point.
+func (p *Point) Scale(n int) {
+	p.X *= n
+	p.Y *= n
+}
+
+func (c Celsius) Scale(n int) Celsius { return c * Celsius(n) }
+`
+
+func TestGoSymbol_lineRange(t *testing.T) {
+	lines := strings.Split(goSymbolSource, "\n")
+	cases := []struct {
+		symbol   goSymbol
+		expected []string
+	}{
+		{goSymbol{"const", "Answer", true}, []string{"// Answer is the answer.", "const Answer = 42"}},
+		{goSymbol{"const", "Answer", false}, []string{"const Answer = 42"}},
+		{goSymbol{"const", "Red", true}, []string{"	// Red is red.", "	Red = iota"}},
+		{goSymbol{"const", "Green", true}, []string{"	Green // Green is green."}},
+		{goSymbol{"type", "Point", false}, []string{"type Point struct {", "	X, Y int", "}"}},
+		{goSymbol{"type", "Celsius", true}, []string{"	// Celsius is temperature.", "	Celsius float64"}},
+		{goSymbol{"func", "Add", true}, []string{
+			"// Add returns sum of two points.",
+			"func Add(a, b Point) Point {",
+			"	return Point{a.X + b.X, a.Y + b.Y}",
+			"}",
+		}},
+		{goSymbol{"method", "Point.Scale", false}, []string{
+			"func (p *Point) Scale(n int) {",
+			"	p.X *= n",
+			"	p.Y *= n",
+			"}",
+		}},
+		{goSymbol{"method", "Celsius.Scale", true}, []string{
+			"func (c Celsius) Scale(n int) Celsius { return c * Celsius(n) }",
+		}},
+	}
+	for _, c := range cases {
+		first, last, err := c.symbol.lineRange("demo.go", []byte(goSymbolSource))
+		assert.Nil(t, err, c.symbol.String())
+		assert.Equal(t, c.expected, lines[first-1:last], c.symbol.String())
+	}
+}
+
+func TestGoSymbol_lineRange_invalid(t *testing.T) {
+	cases := []struct {
+		src    string
+		symbol goSymbol
+	}{
+		{goSymbolSource, goSymbol{"func", "Scale", true}},
+		{goSymbolSource, goSymbol{"type", "Add", true}},
+		{goSymbolSource, goSymbol{"const", "Point", true}},
+		{goSymbolSource, goSymbol{"method", "Point.Add", true}},
+		{"package demo\nfunc init() {}\nfunc init() {}\n", goSymbol{"func", "init", true}},
+		{"not go source", goSymbol{"func", "main", true}},
+	}
+	for _, c := range cases {
+		_, _, err := c.symbol.lineRange("demo.go", []byte(c.src))
+		assert.NotNil(t, err, c.symbol.String())
+	}
+}
@@ -1,6 +1,21 @@
 package maya
 
-import "strings"
+import (
+	"bytes"
+	"strings"
+)
+
+// splitList splits comma separated text and drops empty items.
+func splitList(text string) []string {
+	items := []string{}
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
 
 func sanitizeLineFeedMultiLine(lines []string) []string {
 	for i, line := range lines {
@@ -12,3 +27,74 @@ func sanitizeLineFeedMultiLine(lines []string) []string {
 func sanitizeLineFeedSingleLine(line string) string {
 	return strings.Replace(line, "\r", "", -1)
 }
+
+// expandTabs replaces tabs with spaces up to the next tab stop.
+func expandTabs(line string, width int) string {
+	if width <= 0 || !strings.Contains(line, "\t") {
+		return line
+	}
+	var buf bytes.Buffer
+	column := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := width - column%width
+			buf.WriteString(strings.Repeat(" ", n))
+			column += n
+			continue
+		}
+		buf.WriteRune(r)
+		column++
+	}
+	return buf.String()
+}
+
+// dedentLines removes the longest common leading whitespace.
+// blank lines and lines which are not in the file are not considered.
+func dedentLines(lines []sourceLine) []sourceLine {
+	prefix := ""
+	found := false
+	for _, line := range lines {
+		if line.num == 0 || strings.TrimSpace(line.text) == "" {
+			continue
+		}
+		indent := line.text[:len(line.text)-len(strings.TrimLeft(line.text, " \t"))]
+		if !found {
+			prefix = indent
+			found = true
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if prefix == "" {
+		return lines
+	}
+
+	dedented := make([]sourceLine, len(lines))
+	for i, line := range lines {
+		if line.num != 0 {
+			if strings.TrimSpace(line.text) == "" {
+				line.text = ""
+			} else {
+				line.text = strings.TrimPrefix(line.text, prefix)
+			}
+		}
+		dedented[i] = line
+	}
+	return dedented
+}
+
+// trimBlankLines removes blank lines at the beginning and the end.
+func trimBlankLines(lines []sourceLine) []sourceLine {
+	isBlank := func(line sourceLine) bool {
+		return strings.Trim(line.text, "\t\r ") == ""
+	}
+	for len(lines) > 0 && isBlank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && isBlank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
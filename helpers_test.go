@@ -0,0 +1,58 @@
+package maya
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_expandTabs(t *testing.T) {
+	cases := []struct {
+		line     string
+		width    int
+		expected string
+	}{
+		{"\tfoo", 4, "    foo"},
+		{"a\tb", 4, "a   b"},
+		{"abcd\tb", 4, "abcd    b"},
+		{"\t\tfoo", 2, "    foo"},
+		{"\tfoo", 0, "\tfoo"},
+		{"한\tb", 4, "한   b"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, expandTabs(c.line, c.width), c.line)
+	}
+}
+
+func Test_dedentLines(t *testing.T) {
+	cases := []struct {
+		lines    []sourceLine
+		expected []sourceLine
+	}{
+		{
+			[]sourceLine{{1, "    if ok {"}, {2, "        return"}, {3, "  "}, {4, "    }"}},
+			[]sourceLine{{1, "if ok {"}, {2, "    return"}, {3, ""}, {4, "}"}},
+		},
+		{
+			[]sourceLine{{1, "\t\tfoo"}, {0, "..."}, {9, "\tbar"}},
+			[]sourceLine{{1, "\tfoo"}, {0, "..."}, {9, "bar"}},
+		},
+		{
+			[]sourceLine{{1, "foo"}, {2, "  bar"}},
+			[]sourceLine{{1, "foo"}, {2, "  bar"}},
+		},
+		{
+			[]sourceLine{{1, "\tfoo"}, {2, "  bar"}},
+			[]sourceLine{{1, "\tfoo"}, {2, "  bar"}},
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, dedentLines(c.lines))
+	}
+}
+
+func Test_trimBlankLines(t *testing.T) {
+	lines := []sourceLine{{1, ""}, {2, "a"}, {3, " "}, {4, "b"}, {5, "\t"}}
+	assert.Equal(t, []sourceLine{{2, "a"}, {3, " "}, {4, "b"}}, trimBlankLines(lines))
+	assert.Len(t, trimBlankLines([]sourceLine{{1, ""}, {2, " "}}), 0)
+}
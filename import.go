@@ -0,0 +1,232 @@
+package maya
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// importers convert keys of the generator to keys of maya.
+// they are the reverse of Preprocess.
+var importers = map[string]func(*ArticleMetadata){
+	ModePelican: importPelican,
+	ModeHugo:    importHugo,
+	ModeJekyll:  importJekyll,
+}
+
+// Import converts a post of pelican, hugo or jekyll to maya source with YAML front matter.
+// If shortcodes is true, youtube and gist shortcodes of hugo are rewritten to maya blocks.
+func Import(text string, from string, shortcodes bool) (string, error) {
+	importer, ok := importers[from]
+	if !ok {
+		return "", fmt.Errorf("cannot import from %s, expected pelican, hugo or jekyll", from)
+	}
+	text = strings.Replace(text, "\r", "", -1)
+
+	var metadata *ArticleMetadata
+	var content string
+	if from == ModePelican {
+		metadata, content = parsePelicanMetadata(text)
+	} else {
+		article, err := NewArticle(text, ModeEmpty)
+		if err != nil {
+			return "", err
+		}
+		metadata, err = article.Metadata()
+		if err != nil {
+			return "", fmt.Errorf("metadata: %v", err)
+		}
+		content = article.ContentText
+	}
+	importer(metadata)
+
+	if shortcodes && from == ModeHugo {
+		content = rewriteShortcodes(content)
+	}
+	if len(metadata.Table) == 0 {
+		return content, nil
+	}
+	header, err := encodeYAML(metadata.Table)
+	if err != nil {
+		return "", err
+	}
+	return "---\n" + header + "---\n" + content, nil
+}
+
+var pelicanMetadataRe = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.*)$`)
+
+// parsePelicanMetadata splits Key: value lines at the top of markdown from content.
+// indented lines continue the value of the previous key and blank line ends metadata.
+// lines without title or date are content like "Note: ...".
+func parsePelicanMetadata(text string) (*ArticleMetadata, string) {
+	metadata := &ArticleMetadata{Table: []MetadataKeyValue{}}
+	lines := strings.Split(text, "\n")
+	i := 0
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if n := len(metadata.Table); n > 0 && (line[0] == ' ' || line[0] == '\t') {
+			last := &metadata.Table[n-1]
+			last.value = last.Value() + "\n" + strings.TrimSpace(line)
+			continue
+		}
+		m := pelicanMetadataRe.FindStringSubmatch(line)
+		if m == nil {
+			break
+		}
+		metadata.Table = append(metadata.Table, MetadataKeyValue{
+			Key:   strings.ToLower(m[1]),
+			value: strings.TrimSpace(m[2]),
+		})
+	}
+	if metadata.find("title") < 0 && metadata.find("date") < 0 {
+		return &ArticleMetadata{Table: []MetadataKeyValue{}}, text
+	}
+	if i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	return metadata, strings.Join(lines[i:], "\n")
+}
+
+func importPelican(m *ArticleMetadata) {
+	// tags of pelican are separated by comma
+	if i := m.find("tags"); i >= 0 {
+		list := []interface{}{}
+		for _, tag := range splitList(m.Table[i].Value()) {
+			list = append(list, tag)
+		}
+		m.Table[i].value = list
+	}
+}
+
+func importHugo(m *ArticleMetadata) {
+	m.rename("lastmod", "modified")
+	m.rename("description", "summary")
+	if i := m.find("draft"); i >= 0 && m.Table[i].value == true {
+		m.Table[i] = MetadataKeyValue{Key: "status", value: "draft"}
+	}
+}
+
+func importJekyll(m *ArticleMetadata) {
+	m.rename("last_modified_at", "modified")
+	m.rename("excerpt", "summary")
+	// maya has a single category like pelican. many categories are kept as they are
+	if i := m.find("categories"); i >= 0 && m.find("category") < 0 {
+		categories := m.values("categories")
+		if text, ok := m.Table[i].value.(string); ok {
+			// categories of string are separated by space
+			categories = []interface{}{}
+			for _, c := range strings.Fields(text) {
+				categories = append(categories, c)
+			}
+		}
+		if len(categories) == 1 {
+			m.Table[i] = MetadataKeyValue{Key: "category", value: categories[0]}
+		}
+	}
+	if i := m.find("published"); i >= 0 && m.Table[i].value == false {
+		m.Table[i] = MetadataKeyValue{Key: "status", value: "draft"}
+	}
+}
+
+var (
+	shortcodeRe    = regexp.MustCompile(`^\{\{([<%])\s*(youtube|gist)\s+(.*?)\s*([>%])\}\}$`)
+	shortcodeArgRe = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|(\S+))|"([^"]*)"|(\S+)`)
+)
+
+// youtubeShortcodeParams are named parameters of youtube shortcode
+// which maya:youtube supports.
+var youtubeShortcodeParams = []struct {
+	name string
+	key  string
+}{
+	{"id", "video_id"},
+	{"start", "start"},
+	{"end", "end"},
+	{"autoplay", "autoplay"},
+	{"loading", "loading"},
+	{"title", "title"},
+}
+
+// rewriteShortcodes replaces youtube and gist shortcodes of hugo in their own lines
+// with maya blocks. shortcodes in code blocks and unknown parameters are kept.
+func rewriteShortcodes(content string) string {
+	lines := strings.Split(content, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if block, ok := shortcodeBlock(trimmed); ok {
+			lines[i] = block
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shortcodeBlock returns maya block of youtube or gist shortcode.
+func shortcodeBlock(line string) (string, bool) {
+	m := shortcodeRe.FindStringSubmatch(line)
+	if m == nil || (m[1] == "<") != (m[4] == ">") {
+		return "", false
+	}
+	positional := []string{}
+	named := map[string]string{}
+	for _, arg := range shortcodeArgRe.FindAllStringSubmatch(m[3], -1) {
+		switch {
+		case arg[1] != "":
+			named[arg[1]] = arg[2] + arg[3]
+		case arg[4] != "":
+			positional = append(positional, arg[4])
+		default:
+			positional = append(positional, arg[5])
+		}
+	}
+
+	params := [][2]string{}
+	switch m[2] {
+	case "youtube":
+		if len(positional) == 1 && len(named) == 0 {
+			named["id"] = positional[0]
+		} else if len(positional) > 0 {
+			return "", false
+		}
+		for _, p := range youtubeShortcodeParams {
+			if value, ok := named[p.name]; ok {
+				params = append(params, [2]string{p.key, value})
+				delete(named, p.name)
+			}
+		}
+		if len(named) > 0 || len(params) == 0 || params[0][0] != "video_id" {
+			return "", false
+		}
+	case "gist":
+		// {{< gist user id "file" >}}
+		if len(named) > 0 || len(positional) < 2 || len(positional) > 3 {
+			return "", false
+		}
+		params = append(params, [2]string{"id", positional[1]})
+		if len(positional) == 3 {
+			params = append(params, [2]string{"file", positional[2]})
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "~~~maya:%s\n", m[2])
+	for _, p := range params {
+		fmt.Fprintf(&buf, "%s=%s\n", p[0], p[1])
+	}
+	buf.WriteString("~~~")
+	return buf.String(), true
+}
@@ -0,0 +1,216 @@
+package maya
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImport(t *testing.T) {
+	cases := []struct {
+		from     string
+		text     string
+		expected string
+	}{
+		{
+			ModePelican,
+			strings.Join([]string{
+				"Title: hello",
+				"Date: 2010-12-03 10:20",
+				"Tags: a, b",
+				"Summary: line",
+				"    next",
+				"",
+				"content",
+			}, "\n"),
+			strings.Join([]string{
+				"---",
+				"title: hello",
+				"date: 2010-12-03 10:20",
+				"tags:",
+				"- a",
+				"- b",
+				"summary: |-",
+				"  line",
+				"  next",
+				"---",
+				"content",
+			}, "\n"),
+		},
+		{
+			ModePelican,
+			"content only",
+			"content only",
+		},
+		{
+			ModePelican,
+			"Note: this post is old.\nSee: the new post\n\ncontent",
+			"Note: this post is old.\nSee: the new post\n\ncontent",
+		},
+		{
+			ModeHugo,
+			strings.Join([]string{
+				"+++",
+				`title = "hello"`,
+				"weight = 2",
+				"+++",
+				"content",
+			}, "\n"),
+			strings.Join([]string{
+				"---",
+				"title: hello",
+				"weight: 2",
+				"---",
+				"content",
+			}, "\n"),
+		},
+		{
+			ModeHugo,
+			strings.Join([]string{
+				"+++",
+				"date = 2016-02-20",
+				"lastmod = 2016-02-21T10:20:00+09:00",
+				`description = "short"`,
+				"draft = true",
+				"+++",
+				"content",
+			}, "\n"),
+			strings.Join([]string{
+				"---",
+				"date: 2016-02-20",
+				"modified: 2016-02-21T10:20:00+09:00",
+				"summary: short",
+				"status: draft",
+				"---",
+				"content",
+			}, "\n"),
+		},
+		{
+			ModeHugo,
+			strings.Join([]string{
+				"---",
+				"draft: false",
+				"summary: kept",
+				"description: short",
+				"---",
+				"content",
+			}, "\n"),
+			strings.Join([]string{
+				"---",
+				"draft: false",
+				"summary: kept",
+				"description: short",
+				"---",
+				"content",
+			}, "\n"),
+		},
+		{
+			ModeJekyll,
+			strings.Join([]string{
+				"---",
+				"title: hello",
+				"categories: [a]",
+				"excerpt: short",
+				"last_modified_at: 2016-02-20",
+				"published: false",
+				"---",
+				"content",
+			}, "\n"),
+			strings.Join([]string{
+				"---",
+				"title: hello",
+				"category: a",
+				"summary: short",
+				"modified: 2016-02-20",
+				"status: draft",
+				"---",
+				"content",
+			}, "\n"),
+		},
+		{
+			ModeJekyll,
+			strings.Join([]string{
+				"---",
+				"categories: a b",
+				"date: 2016-02-20",
+				"---",
+				"content",
+			}, "\n"),
+			strings.Join([]string{
+				"---",
+				"categories: a b",
+				"date: 2016-02-20",
+				"---",
+				"content",
+			}, "\n"),
+		},
+		{
+			ModeJekyll,
+			strings.Join([]string{
+				"---",
+				"categories: [a, b]",
+				"---",
+				"content",
+			}, "\n"),
+			strings.Join([]string{
+				"---",
+				"categories:",
+				"- a",
+				"- b",
+				"---",
+				"content",
+			}, "\n"),
+		},
+	}
+	for _, c := range cases {
+		actual, err := Import(c.text, c.from, false)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, actual)
+	}
+
+	_, err := Import("", ModeZola, false)
+	assert.NotNil(t, err)
+	_, err = Import("+++\ntitle = \n+++\n", ModeHugo, false)
+	assert.NotNil(t, err)
+}
+
+func TestImport_shortcodes(t *testing.T) {
+	text := strings.Join([]string{
+		"{{< youtube ESCv5qDuQIA >}}",
+		`{{< youtube id="ESCv5qDuQIA" start="30" autoplay="true" >}}`,
+		"{{% gist if1live b23494b9e42ae89e6f28 \"factorial.sh\" %}}",
+		"```",
+		"{{< youtube ESCv5qDuQIA >}}",
+		"```",
+		`{{< youtube id="ESCv5qDuQIA" mute="true" >}}`,
+		"see {{< gist if1live b23494b9e42ae89e6f28 >}}",
+	}, "\n")
+	expected := strings.Join([]string{
+		"~~~maya:youtube",
+		"video_id=ESCv5qDuQIA",
+		"~~~",
+		"~~~maya:youtube",
+		"video_id=ESCv5qDuQIA",
+		"start=30",
+		"autoplay=true",
+		"~~~",
+		"~~~maya:gist",
+		"id=b23494b9e42ae89e6f28",
+		"file=factorial.sh",
+		"~~~",
+		"```",
+		"{{< youtube ESCv5qDuQIA >}}",
+		"```",
+		`{{< youtube id="ESCv5qDuQIA" mute="true" >}}`,
+		"see {{< gist if1live b23494b9e42ae89e6f28 >}}",
+	}, "\n")
+
+	actual, err := Import(text, ModeHugo, true)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+
+	actual, err = Import(text, ModeHugo, false)
+	assert.Nil(t, err)
+	assert.Equal(t, text, actual)
+}
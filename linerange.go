@@ -0,0 +1,146 @@
+package maya
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// sourceLine is a line of viewed file with its 1-based line number.
+// num is zero for lines which are not in the file, like elision marker.
+type sourceLine struct {
+	num  int
+	text string
+}
+
+// numberLines numbers lines beginning at first.
+func numberLines(lines []string, first int) []sourceLine {
+	numbered := make([]sourceLine, len(lines))
+	for i, line := range lines {
+		numbered[i] = sourceLine{first + i, line}
+	}
+	return numbered
+}
+
+func lineTexts(lines []sourceLine) []string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.text
+	}
+	return texts
+}
+
+// lineRange is 1-based inclusive range of lines.
+type lineRange struct {
+	first int
+	last  int
+}
+
+var (
+	singleLineRe = regexp.MustCompile(`^(-?\d+)$`)
+	lineRangeRe  = regexp.MustCompile(`^(-?\d+)?-(-?\d+)?$`)
+)
+
+// parseLineRanges parses comma separated ranges like 10-25, 10- and -5-.
+// negative number counts from the end of file. -1 is the last line.
+func parseLineRanges(spec string, total int) ([]lineRange, error) {
+	items := splitList(spec)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("empty line range: %q", spec)
+	}
+
+	ranges := []lineRange{}
+	for _, item := range items {
+		first, last := 1, total
+		if m := singleLineRe.FindStringSubmatch(item); m != nil {
+			n, err := resolveLineNumber(m[1], total)
+			if err != nil {
+				return nil, err
+			}
+			first, last = n, n
+		} else if m := lineRangeRe.FindStringSubmatch(item); m != nil {
+			var err error
+			if m[1] != "" {
+				if first, err = resolveLineNumber(m[1], total); err != nil {
+					return nil, err
+				}
+			}
+			if m[2] != "" {
+				if last, err = resolveLineNumber(m[2], total); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			return nil, fmt.Errorf("invalid line range: %q", item)
+		}
+
+		if first < 1 || last > total {
+			return nil, fmt.Errorf("line range %s is outside of file with %d lines", item, total)
+		}
+		if first > last {
+			return nil, fmt.Errorf("line range %s is reversed", item)
+		}
+		ranges = append(ranges, lineRange{first, last})
+	}
+	return ranges, nil
+}
+
+func resolveLineNumber(text string, total int) (int, error) {
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("line numbers are 1-based, got 0")
+	}
+	if n < 0 {
+		n = total + n + 1
+	}
+	return n, nil
+}
+
+// selectLineRanges picks ranges of lines.
+// elision is inserted between ranges which are not adjacent.
+func selectLineRanges(lines []sourceLine, ranges []lineRange, elision string) []sourceLine {
+	selected := []sourceLine{}
+	for i, r := range ranges {
+		if i > 0 && ranges[i-1].last+1 != r.first {
+			selected = append(selected, sourceLine{0, elision})
+		}
+		selected = append(selected, lines[r.first-1:r.last]...)
+	}
+	return selected
+}
+
+// countLines returns number of lines without the empty line after last line feed.
+func countLines(lines []string) int {
+	n := len(lines)
+	if n > 0 && lines[n-1] == "" {
+		n--
+	}
+	return n
+}
+
+// expandRanges returns every line number in ranges.
+func expandRanges(ranges []lineRange) []int {
+	nums := []int{}
+	for _, r := range ranges {
+		for i := r.first; i <= r.last; i++ {
+			nums = append(nums, i)
+		}
+	}
+	return nums
+}
+
+// compressRanges joins sorted line numbers into ranges.
+func compressRanges(nums []int) []lineRange {
+	ranges := []lineRange{}
+	for _, n := range nums {
+		if last := len(ranges) - 1; last >= 0 && ranges[last].last+1 == n {
+			ranges[last].last = n
+			continue
+		}
+		ranges = append(ranges, lineRange{n, n})
+	}
+	return ranges
+}
@@ -0,0 +1,68 @@
+package maya
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLineRanges(t *testing.T) {
+	cases := []struct {
+		spec     string
+		expected []lineRange
+	}{
+		{"10-25", []lineRange{{10, 25}}},
+		{"10-", []lineRange{{10, 30}}},
+		{"-5-", []lineRange{{26, 30}}},
+		{"-5--2", []lineRange{{26, 29}}},
+		{"-10", []lineRange{{21, 21}}},
+		{"7", []lineRange{{7, 7}}},
+		{"-1", []lineRange{{30, 30}}},
+		{"1-3, 10-12", []lineRange{{1, 3}, {10, 12}}},
+	}
+	for _, c := range cases {
+		actual, err := parseLineRanges(c.spec, 30)
+		assert.Nil(t, err, c.spec)
+		assert.Equal(t, c.expected, actual, c.spec)
+	}
+}
+
+func TestParseLineRanges_invalid(t *testing.T) {
+	cases := []string{
+		"",
+		"0",
+		"0-3",
+		"1-31",
+		"31-",
+		"-31-",
+		"5-3",
+		"1-3,abc",
+		"1--",
+		"1-2-3",
+	}
+	for _, spec := range cases {
+		_, err := parseLineRanges(spec, 30)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestSelectLineRanges(t *testing.T) {
+	lines := numberLines([]string{"a", "b", "c", "d", "e"}, 1)
+	cases := []struct {
+		ranges   []lineRange
+		expected []sourceLine
+	}{
+		{[]lineRange{{2, 3}}, []sourceLine{{2, "b"}, {3, "c"}}},
+		{[]lineRange{{1, 2}, {3, 3}}, []sourceLine{{1, "a"}, {2, "b"}, {3, "c"}}},
+		{[]lineRange{{1, 1}, {4, 5}}, []sourceLine{{1, "a"}, {0, "..."}, {4, "d"}, {5, "e"}}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, selectLineRanges(lines, c.ranges, "..."))
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	assert.Equal(t, 2, countLines([]string{"a", "b", ""}))
+	assert.Equal(t, 2, countLines([]string{"a", "b"}))
+	assert.Equal(t, 0, countLines([]string{""}))
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/if1live/maya"
+	"github.com/op/go-logging"
+)
+
+type batch struct {
+	src     string
+	dst     string
+	include []string
+	exclude []string
+	force   bool
+}
+
+// job builds or copies a file.
+type job struct {
+	src   string
+	dst   string
+	build bool
+	// force skips up-to-date check
+	force bool
+}
+
+func splitPatterns(text string) []string {
+	patterns := []string{}
+	for _, p := range strings.Split(text, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// matchPatterns reports whether relative path or its base name matches any pattern.
+func matchPatterns(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	base := filepath.Base(rel)
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *batch) run() error {
+	log := logging.MustGetLogger("maya")
+
+	jobs, err := b.jobs()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, j := range jobs {
+		if err := b.runJob(j); err != nil {
+			log.Error(err.Error())
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) failed", failed)
+	}
+	return nil
+}
+
+func (b *batch) runJob(j job) error {
+	if b.force {
+		j.force = true
+	}
+	if j.build {
+		return b.build(j)
+	}
+	return b.copy(j)
+}
+
+func (b *batch) jobs() ([]job, error) {
+	dst, err := filepath.Abs(b.dst)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []job{}
+	err = walkTree(b.src, b.exclude, func(path, rel string, info os.FileInfo) error {
+		if info.IsDir() {
+			// output in the source tree is not a source
+			if abs, _ := filepath.Abs(path); abs == dst {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		jobs = append(jobs, job{
+			src:   path,
+			dst:   filepath.Join(b.dst, rel),
+			build: matchPatterns(b.include, rel),
+		})
+		return nil
+	})
+	return jobs, err
+}
+
+// walkTree calls fn with files and directories under root and paths relative to root.
+// entries matching exclude are skipped and excluded directories are not descended.
+// fn returns filepath.SkipDir to skip a directory.
+func walkTree(root string, exclude []string, fn func(path, rel string, info os.FileInfo) error) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if matchPatterns(exclude, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return fn(path, rel, info)
+	})
+}
+
+func (b *batch) build(j job) error {
+	log := logging.MustGetLogger("maya")
+	path, outPath := j.src, j.dst
+	article, err := loadArticle(path)
+	if err != nil {
+		return err
+	}
+
+	inputs := append([]string{path}, article.Dependencies()...)
+	inputs = append(inputs, templateFiles()...)
+	if !j.force && isUpToDate(outPath, inputs) {
+		log.Debugf("up to date: %s", outPath)
+		return nil
+	}
+
+	log.Noticef("build: %s -> %s", path, outPath)
+	return writeArticle(article, outPath)
+}
+
+func (b *batch) copy(j job) error {
+	log := logging.MustGetLogger("maya")
+	path, outPath := j.src, j.dst
+	if !j.force && isUpToDate(outPath, []string{path}) {
+		return nil
+	}
+
+	log.Noticef("copy: %s -> %s", path, outPath)
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func writeArticle(article *maya.Article, outPath string) error {
+	output, err := article.OutputStringContext(_ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(output)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isUpToDate reports whether output is newer than every input.
+// missing input is treated as changed.
+func isUpToDate(output string, inputs []string) bool {
+	outInfo, err := os.Stat(output)
+	if err != nil {
+		return false
+	}
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return false
+		}
+		if info.ModTime().After(outInfo.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/if1live/maya"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFiles creates files of relative path -> content under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func newBatchTree(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "maya-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, filepath.Join(dir, "src"), map[string]string{
+		"a.md":             "hello",
+		"img/x.png":        "png",
+		"posts/b.md":       "world",
+		"drafts/c.md":      "draft",
+		"drafts/img/y.png": "png",
+		".git/config":      "git",
+		"out/old.md":       "output",
+	})
+	return dir
+}
+
+func TestMatchPatterns(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		rel      string
+		expected bool
+	}{
+		{[]string{"*.md"}, "a.md", true},
+		// base name matches in subdirectories
+		{[]string{"*.md"}, filepath.Join("posts", "a.md"), true},
+		{[]string{"posts/*.md"}, filepath.Join("posts", "a.md"), true},
+		{[]string{"*.md"}, "a.png", false},
+		{[]string{".*"}, filepath.Join("posts", ".hidden"), true},
+		{[]string{"drafts"}, "drafts", true},
+		{[]string{}, "a.md", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, matchPatterns(c.patterns, c.rel), c.rel)
+	}
+
+	assert.Equal(t, []string{"*.md", "drafts"}, splitPatterns(" *.md, ,drafts "))
+}
+
+func TestWalkTree(t *testing.T) {
+	dir := newBatchTree(t)
+	defer os.RemoveAll(dir)
+
+	visited := []string{}
+	err := walkTree(filepath.Join(dir, "src"), []string{".*", "drafts"}, func(path, rel string, info os.FileInfo) error {
+		visited = append(visited, filepath.ToSlash(rel))
+		return nil
+	})
+	assert.Nil(t, err)
+	sort.Strings(visited)
+	// excluded directories are not descended
+	assert.Equal(t, []string{"a.md", "img", "img/x.png", "out", "out/old.md", "posts", "posts/b.md"}, visited)
+}
+
+func TestBatch_jobs(t *testing.T) {
+	dir := newBatchTree(t)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(src, "out")
+	b := &batch{
+		src:     src,
+		dst:     dst,
+		include: []string{"*.md"},
+		exclude: []string{".*", "drafts"},
+	}
+	jobs, err := b.jobs()
+	assert.Nil(t, err)
+
+	expected := []job{
+		{src: filepath.Join(src, "a.md"), dst: filepath.Join(dst, "a.md"), build: true},
+		{src: filepath.Join(src, "img", "x.png"), dst: filepath.Join(dst, "img", "x.png"), build: false},
+		{src: filepath.Join(src, "posts", "b.md"), dst: filepath.Join(dst, "posts", "b.md"), build: true},
+	}
+	// output directory in the source tree is skipped
+	assert.Equal(t, expected, jobs)
+}
+
+func TestBatch_run(t *testing.T) {
+	_mode = maya.ModeEmpty
+	dir := newBatchTree(t)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	b := &batch{
+		src:     src,
+		dst:     dst,
+		include: []string{"*.md"},
+		exclude: []string{".*", "drafts"},
+	}
+	assert.Nil(t, b.run())
+
+	// documents are built and assets are copied to mirrored paths
+	assert.Equal(t, "hello", strings.TrimSpace(readFile(t, filepath.Join(dst, "a.md"))))
+	assert.Equal(t, "world", strings.TrimSpace(readFile(t, filepath.Join(dst, "posts", "b.md"))))
+	assert.Equal(t, "png", readFile(t, filepath.Join(dst, "img", "x.png")))
+	// excluded directories are not descended
+	for _, rel := range []string{"drafts", ".git"} {
+		_, err := os.Stat(filepath.Join(dst, rel))
+		assert.True(t, os.IsNotExist(err), rel)
+	}
+
+	// up to date output is not rebuilt
+	output := filepath.Join(dst, "a.md")
+	now := time.Now()
+	ioutil.WriteFile(output, []byte("edited"), 0644)
+	os.Chtimes(filepath.Join(src, "a.md"), now.Add(-time.Hour), now.Add(-time.Hour))
+	os.Chtimes(output, now, now)
+	assert.Nil(t, b.run())
+	assert.Equal(t, "edited", readFile(t, output))
+
+	// stale output is rebuilt
+	os.Chtimes(filepath.Join(src, "a.md"), now.Add(time.Hour), now.Add(time.Hour))
+	assert.Nil(t, b.run())
+	assert.Equal(t, "hello", strings.TrimSpace(readFile(t, output)))
+
+	// force rebuilds every file
+	ioutil.WriteFile(output, []byte("edited"), 0644)
+	os.Chtimes(output, now.Add(2*time.Hour), now.Add(2*time.Hour))
+	b.force = true
+	assert.Nil(t, b.run())
+	assert.Equal(t, "hello", strings.TrimSpace(readFile(t, output)))
+}
+
+func TestBatch_run_dstInSource(t *testing.T) {
+	_mode = maya.ModeEmpty
+	dir := newBatchTree(t)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(src, "out")
+	b := &batch{src: src, dst: dst, include: []string{"*.md"}, exclude: []string{".*", "drafts"}}
+	assert.Nil(t, b.run())
+
+	// output directory in the source tree is not built into itself
+	assert.Equal(t, "hello", strings.TrimSpace(readFile(t, filepath.Join(dst, "a.md"))))
+	_, err := os.Stat(filepath.Join(dst, "out"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestIsUpToDate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya-cli")
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"in.md": "", "out.md": ""})
+	input := filepath.Join(dir, "in.md")
+	output := filepath.Join(dir, "out.md")
+
+	now := time.Now()
+	os.Chtimes(input, now.Add(-time.Hour), now.Add(-time.Hour))
+	os.Chtimes(output, now, now)
+	assert.True(t, isUpToDate(output, []string{input}))
+
+	os.Chtimes(input, now.Add(time.Hour), now.Add(time.Hour))
+	assert.False(t, isUpToDate(output, []string{input}))
+
+	// missing input or output is changed
+	assert.False(t, isUpToDate(output, []string{filepath.Join(dir, "missing.md")}))
+	assert.False(t, isUpToDate(filepath.Join(dir, "missing.md"), []string{input}))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/if1live/maya"
+	"github.com/op/go-logging"
+)
+
+// runCache handles `maya-cli cache <subcommand>` and returns exit code.
+func runCache(args []string) int {
+	if len(args) == 0 || args[0] != "prune" {
+		fmt.Fprintln(os.Stderr, "usage: maya-cli cache prune [flags] file-or-dir...")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("cache prune", flag.ExitOnError)
+	fs.StringVar(&_cacheDir, "cache-dir", "", "cache directory of maya:execute. default is ./cache")
+	fs.StringVar(&_mode, "mode", maya.ModeEmpty, "document mode. used to parse documents")
+	fs.StringVar(&_include, "include", "*.md", "comma separated globs of documents in directories")
+	fs.StringVar(&_exclude, "exclude", ".*", "comma separated globs of files to skip in directories")
+	fs.Parse(args[1:])
+
+	logging.SetLevel(logging.WARNING, "maya")
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "documents required: every entry not referenced by them is removed")
+		return 2
+	}
+
+	keys, err := referencedCacheKeys(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cache is not pruned: %v\n", err)
+		return 1
+	}
+
+	removed, err := maya.PruneCache(_cacheDir, keys)
+	for _, path := range removed {
+		fmt.Println(path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// referencedCacheKeys collects cache keys of every document in paths.
+// it fails if keys of a document can not be computed, entries of the document would be removed otherwise.
+func referencedCacheKeys(paths []string) ([]string, error) {
+	include := splitPatterns(_include)
+	exclude := splitPatterns(_exclude)
+
+	files := []string{}
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		err = walkTree(root, exclude, func(path, rel string, info os.FileInfo) error {
+			if !info.IsDir() && matchPatterns(include, rel) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keys := []string{}
+	for _, file := range files {
+		// keys depend on the directory of the document, not on the cwd of prune
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		article, err := loadArticle(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		found, err := article.CacheKeys()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		keys = append(keys, found...)
+	}
+	return keys, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/if1live/maya"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferencedCacheKeys(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya-cli")
+	defer os.RemoveAll(dir)
+	// temp dir may be a symlink, cwd of the process is not
+	dir, _ = filepath.EvalSymlinks(dir)
+	writeFiles(t, dir, map[string]string{
+		"posts/a.md":     "~~~maya:execute\ncmd=echo hello\ndepends=data.txt\n~~~\n",
+		"posts/data.txt": "1",
+		// depends matches nothing
+		"posts/stale.md": "~~~maya:execute\ncmd=echo stale\ndepends=missing-*.txt\n~~~\n",
+		"cache/old.json": "{}",
+	})
+
+	_mode = maya.ModeEmpty
+	_include = "*.md"
+	_exclude = ".*"
+	_cacheDir = filepath.Join(dir, "cache")
+	defer func() { _cacheDir = "" }()
+
+	article, err := loadArticle(filepath.Join(dir, "posts", "a.md"))
+	assert.Nil(t, err)
+	_, err = article.OutputString()
+	assert.Nil(t, err)
+	expected, err := article.CacheKeys()
+	assert.Nil(t, err)
+	assert.Len(t, expected, 2)
+
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+
+	// broken document stops prune, its entries would be removed otherwise
+	os.Chdir(dir)
+	_, err = referencedCacheKeys([]string{"posts"})
+	assert.NotNil(t, err)
+	keys, err := referencedCacheKeys([]string{filepath.Join("posts", "a.md")})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, keys)
+
+	// prune from other directories with relative paths
+	os.Remove(filepath.Join(dir, "posts", "stale.md"))
+	os.Chdir(filepath.Join(dir, "posts"))
+	keys, err = referencedCacheKeys([]string{"."})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, keys)
+
+	removed, err := maya.PruneCache(_cacheDir, keys)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "cache", "old.json")}, removed)
+	_, err = os.Stat(filepath.Join(dir, "cache", expected[0]+".json"))
+	assert.Nil(t, err)
+}
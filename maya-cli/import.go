@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/if1live/maya"
+	"github.com/op/go-logging"
+)
+
+// runImport handles `maya-cli import` and returns exit code.
+// posts of the generator in src are converted to maya source in dst,
+// other files are copied.
+func runImport(args []string) int {
+	var from string
+	var shortcodes bool
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.StringVar(&from, "from", "", "generator of posts: pelican/hugo/jekyll")
+	fs.BoolVar(&shortcodes, "shortcodes", false, "rewrite youtube and gist shortcodes of hugo to maya blocks")
+	fs.StringVar(&_srcDir, "src", "", "content directory of the generator")
+	fs.StringVar(&_dstDir, "dst", "", "destination directory of maya source")
+	fs.StringVar(&_include, "include", "*.md,*.markdown", "comma separated globs of posts to import")
+	fs.StringVar(&_exclude, "exclude", ".*", "comma separated globs of files to skip")
+	fs.BoolVar(&_force, "force", false, "import every file even if it is up to date")
+	fs.StringVar(&_logLevel, "log", "NOTICE", "log level: critical, error, warning, notice, info, debug")
+	fs.Parse(args)
+
+	logLevel, _ := logging.LogLevel(_logLevel)
+	logging.SetLevel(logLevel, "maya")
+	logging.SetFormatter(_formatter)
+	log := logging.MustGetLogger("maya")
+
+	switch from {
+	case maya.ModePelican, maya.ModeHugo, maya.ModeJekyll:
+	default:
+		from = ""
+	}
+	if from == "" || _srcDir == "" || _dstDir == "" {
+		fmt.Fprintln(os.Stderr, "usage: maya-cli import -from=pelican|hugo|jekyll -src=dir -dst=dir [flags]")
+		return 2
+	}
+
+	b := &batch{
+		src:     _srcDir,
+		dst:     _dstDir,
+		include: splitPatterns(_include),
+		exclude: splitPatterns(_exclude),
+		force:   _force,
+	}
+	jobs, err := b.jobs()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	failed := 0
+	for _, j := range jobs {
+		j.force = _force
+		if j.build {
+			err = importPost(j, from, shortcodes)
+		} else {
+			err = b.copy(j)
+		}
+		if err != nil {
+			log.Error(err.Error())
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d file(s) failed\n", failed)
+		return 1
+	}
+	return 0
+}
+
+func importPost(j job, from string, shortcodes bool) error {
+	log := logging.MustGetLogger("maya")
+	if !j.force && isUpToDate(j.dst, []string{j.src}) {
+		log.Debugf("up to date: %s", j.dst)
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(j.src)
+	if err != nil {
+		return err
+	}
+	output, err := maya.Import(string(data), from, shortcodes)
+	if err != nil {
+		return fmt.Errorf("%s: %v", j.src, err)
+	}
+
+	log.Noticef("import: %s -> %s", j.src, j.dst)
+	if err := os.MkdirAll(filepath.Dir(j.dst), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(j.dst, []byte(output), 0644)
+}
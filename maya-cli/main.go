@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/if1live/maya"
 	"github.com/op/go-logging"
@@ -12,12 +18,74 @@ var _mode string
 var _filePath string
 var _logLevel string
 var _outputPath string
+var _collectErrors bool
+var _workers int
+var _timeout time.Duration
+
+var _cacheDir string
+var _noCache bool
+var _refreshCache bool
+
+var _root string
+var _searchPaths string
+var _gistDir string
+
+var _srcDir string
+var _dstDir string
+var _include string
+var _exclude string
+var _force bool
+
+var _templates templateFlags
+var _watch bool
+var _watchInterval time.Duration
+var _debounce time.Duration
+
+var _loader = maya.NewTemplateLoader()
+
+// _ctx is canceled by interrupt signal to stop running commands
+var _ctx = context.Background()
+
+// templateFlags is list of mode=path
+type templateFlags []string
+
+func (f *templateFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *templateFlags) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("invalid template: %s, expected mode=path", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
 
 func init() {
-	flag.StringVar(&_mode, "mode", "", "document mode: pelican/hugo")
+	flag.StringVar(&_mode, "mode", "", "document mode: pelican/hugo/jekyll/hexo/zola/eleventy/gatsby/json/empty")
 	flag.StringVar(&_filePath, "file", "", "file path: xxx.md")
 	flag.StringVar(&_logLevel, "log", "ERROR", "log level: critical, error, warning, notice, info, debug")
 	flag.StringVar(&_outputPath, "output", "stdout", "output path: xxx.md")
+	flag.BoolVar(&_collectErrors, "all-errors", false, "report every broken block")
+	flag.IntVar(&_workers, "workers", 1, "number of blocks evaluated concurrently")
+	flag.DurationVar(&_timeout, "timeout", 0, "default timeout of maya:execute. 0 means no timeout")
+	flag.StringVar(&_cacheDir, "cache-dir", "", "cache directory of maya:execute. default is ./cache")
+	flag.BoolVar(&_noCache, "no-cache", false, "do not read or write cache of maya:execute")
+	flag.BoolVar(&_refreshCache, "refresh-cache", false, "ignore cached output of maya:execute and write new one")
+	flag.StringVar(&_root, "root", "", "project directory. files outside of it are not read by maya:view")
+	flag.StringVar(&_searchPaths, "search-paths", "", "comma separated directories where maya:view looks up files")
+	flag.StringVar(&_gistDir, "gist-dir", "", "read inline gists from dir/<id>/<file> instead of GitHub")
+
+	flag.StringVar(&_srcDir, "src", "", "source directory. build every file in the tree")
+	flag.StringVar(&_dstDir, "dst", "", "destination directory. used with -src")
+	flag.StringVar(&_include, "include", "*.md", "comma separated globs of files to build. used with -src")
+	flag.StringVar(&_exclude, "exclude", ".*", "comma separated globs of files to skip. used with -src")
+	flag.BoolVar(&_force, "force", false, "rebuild every file even if it is up to date. used with -src")
+
+	flag.Var(&_templates, "template", "custom metadata template: mode=path. can be repeated")
+	flag.BoolVar(&_watch, "watch", false, "rebuild when input or referenced files change")
+	flag.DurationVar(&_watchInterval, "watch-interval", 500*time.Millisecond, "polling interval of watch mode")
+	flag.DurationVar(&_debounce, "debounce", 300*time.Millisecond, "wait until files are quiet before rebuild")
 }
 
 var _formatter = logging.MustStringFormatter(
@@ -25,6 +93,13 @@ var _formatter = logging.MustStringFormatter(
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		os.Exit(runCache(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	logLevel, _ := logging.LogLevel(_logLevel)
@@ -32,24 +107,129 @@ func main() {
 	logging.SetFormatter(_formatter)
 
 	log := logging.MustGetLogger("maya")
-	if _filePath == "" {
-		log.Fatal("file path required. use -h")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_ctx = ctx
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		log.Warning("interrupted")
+		cancel()
+		signal.Stop(sig)
+	}()
+
+	for _, t := range _templates {
+		tokens := strings.SplitN(t, "=", 2)
+		if err := _loader.RegisterFile(tokens[0], tokens[1]); err != nil {
+			log.Fatal(err.Error())
+		}
 	}
 
-	infile, err := os.Open(_filePath)
-	if err != nil {
+	var p project
+	if _srcDir != "" {
+		if _dstDir == "" {
+			log.Fatal("dst directory required. use -h")
+		}
+		p = &batch{
+			src:     _srcDir,
+			dst:     _dstDir,
+			include: splitPatterns(_include),
+			exclude: splitPatterns(_exclude),
+			force:   _force,
+		}
+	} else {
+		if _filePath == "" {
+			log.Fatal("file path required. use -h")
+		}
+		p = &single{
+			src: _filePath,
+			dst: _outputPath,
+		}
+	}
+
+	if _watch {
+		w := newWatcher(p, _watchInterval, _debounce)
+		w.run()
+		return
+	}
+
+	if err := p.run(); err != nil {
 		log.Fatal(err.Error())
 	}
+}
+
+// project is a set of jobs.
+type project interface {
+	run() error
+	jobs() ([]job, error)
+	runJob(j job) error
+}
+
+// single builds one file.
+type single struct {
+	src string
+	dst string
+}
+
+func (s *single) run() error {
+	return s.runJob(job{src: s.src, dst: s.dst, build: true})
+}
+
+func (s *single) jobs() ([]job, error) {
+	return []job{{src: s.src, dst: s.dst, build: true}}, nil
+}
 
-	outfile := os.Stdout
-	if _outputPath != "stdout" {
-		outfile, err = os.Create(_outputPath)
+func (s *single) runJob(j job) error {
+	article, err := loadArticle(j.src)
+	if err != nil {
+		return err
+	}
+	if j.dst == "stdout" {
+		output, err := article.OutputStringContext(_ctx)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		defer outfile.Close()
+		_, err = os.Stdout.Write([]byte(output))
+		return err
+	}
+	return writeArticle(article, j.dst)
+}
+
+func loadArticle(filePath string) (*maya.Article, error) {
+	infile, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
 	}
+	defer infile.Close()
 
-	article := maya.NewArticleFromReader(infile, _mode)
-	article.Output(outfile)
+	article, err := maya.NewArticleFromReader(infile, _mode)
+	if err != nil {
+		return nil, err
+	}
+	article.FilePath = filePath
+	article.CollectErrors = _collectErrors
+	article.Workers = _workers
+	article.Timeout = _timeout
+	article.CacheDir = _cacheDir
+	article.NoCache = _noCache
+	article.RefreshCache = _refreshCache
+	article.Root = _root
+	article.SearchPaths = splitPatterns(_searchPaths)
+	if _gistDir != "" {
+		article.GistFetcher = &maya.DirGistFetcher{Dir: _gistDir}
+	}
+	article.SetTemplateLoader(_loader)
+	return article, nil
+}
+
+// templateFiles returns custom metadata template files in stable order.
+func templateFiles() []string {
+	files := []string{}
+	for _, path := range _loader.Files() {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
 }
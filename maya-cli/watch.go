@@ -0,0 +1,205 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+// watcher polls files and rebuilds only affected jobs.
+// fsnotify is not used to keep dependencies small.
+type watcher struct {
+	project  project
+	interval time.Duration
+	debounce time.Duration
+
+	// modified times of watched files at the last poll or build
+	snapshot snapshot
+	// job source -> files which the job reads
+	inputs map[string][]string
+}
+
+func newWatcher(p project, interval, debounce time.Duration) *watcher {
+	return &watcher{
+		project:  p,
+		interval: interval,
+		debounce: debounce,
+		snapshot: snapshot{},
+		inputs:   map[string][]string{},
+	}
+}
+
+func (w *watcher) run() {
+	log := logging.MustGetLogger("maya")
+	log.Noticef("watching for changes")
+
+	d := newDebouncer(w.debounce)
+	first := true
+
+	for {
+		jobs, err := w.project.jobs()
+		if err != nil {
+			log.Error(err.Error())
+		}
+
+		// every file is reported as changed at the first poll
+		d.add(w.poll(jobs), time.Now())
+		if changed := d.ready(time.Now()); changed != nil {
+			w.rebuild(jobs, changed, !first)
+			first = false
+		}
+
+		time.Sleep(w.interval)
+	}
+}
+
+// snapshot is file path -> last modified time. missing file has zero time.
+type snapshot map[string]time.Time
+
+func takeSnapshot(paths []string) snapshot {
+	s := snapshot{}
+	for _, path := range paths {
+		modTime := time.Time{}
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		s[path] = modTime
+	}
+	return s
+}
+
+// diff returns files created, modified or deleted since prev.
+func (s snapshot) diff(prev snapshot) map[string]bool {
+	changed := map[string]bool{}
+	for path, modTime := range s {
+		if prevTime, ok := prev[path]; !ok || !prevTime.Equal(modTime) {
+			changed[path] = true
+		}
+	}
+	for path := range prev {
+		if _, ok := s[path]; !ok {
+			changed[path] = true
+		}
+	}
+	return changed
+}
+
+// poll returns files modified since the last poll.
+// new job sources are reported as modified. new dependencies are not,
+// because they are found right after the job is built and recorded by updateInputs.
+func (w *watcher) poll(jobs []job) map[string]bool {
+	paths := templateFiles()
+	for _, j := range jobs {
+		paths = append(paths, j.src)
+	}
+	dependencies := []string{}
+	for _, j := range jobs {
+		dependencies = append(dependencies, w.inputs[j.src]...)
+	}
+	current := takeSnapshot(append(paths, dependencies...))
+
+	prev := snapshot{}
+	for path, modTime := range w.snapshot {
+		prev[path] = modTime
+	}
+	for _, path := range dependencies {
+		if _, ok := prev[path]; !ok {
+			prev[path] = current[path]
+		}
+	}
+	w.snapshot = current
+	return current.diff(prev)
+}
+
+// debouncer collects changes until files are quiet for delay.
+type debouncer struct {
+	delay      time.Duration
+	pending    map[string]bool
+	lastChange time.Time
+}
+
+func newDebouncer(delay time.Duration) *debouncer {
+	return &debouncer{delay: delay, pending: map[string]bool{}}
+}
+
+// add records changed files at now.
+func (d *debouncer) add(changed map[string]bool, now time.Time) {
+	if len(changed) == 0 {
+		return
+	}
+	for path := range changed {
+		d.pending[path] = true
+	}
+	d.lastChange = now
+}
+
+// ready returns pending files and clears them if nothing changed for delay.
+// it returns nil while files are changing.
+func (d *debouncer) ready(now time.Time) map[string]bool {
+	if len(d.pending) == 0 || now.Sub(d.lastChange) < d.delay {
+		return nil
+	}
+	pending := d.pending
+	d.pending = map[string]bool{}
+	return pending
+}
+
+func (w *watcher) rebuild(jobs []job, changed map[string]bool, force bool) {
+	log := logging.MustGetLogger("maya")
+
+	templateChanged := false
+	for mode, path := range _loader.Files() {
+		if !changed[path] {
+			continue
+		}
+		templateChanged = true
+		log.Noticef("reload template: %s", path)
+		if err := _loader.RegisterFile(mode, path); err != nil {
+			log.Error(err.Error())
+		}
+	}
+
+	for _, j := range jobs {
+		if !w.affected(j, changed, templateChanged) {
+			continue
+		}
+		j.force = force
+		if err := w.project.runJob(j); err != nil {
+			log.Error(err.Error())
+		}
+		w.updateInputs(j)
+	}
+}
+
+func (w *watcher) affected(j job, changed map[string]bool, templateChanged bool) bool {
+	if changed[j.src] {
+		return true
+	}
+	if j.build && templateChanged {
+		return true
+	}
+	for _, path := range w.inputs[j.src] {
+		if changed[path] {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *watcher) updateInputs(j job) {
+	if !j.build {
+		return
+	}
+	article, err := loadArticle(j.src)
+	if err != nil {
+		return
+	}
+	w.inputs[j.src] = article.Dependencies()
+	// changes after the build are found by the next poll
+	for path, modTime := range takeSnapshot(w.inputs[j.src]) {
+		if _, ok := w.snapshot[path]; !ok {
+			w.snapshot[path] = modTime
+		}
+	}
+}
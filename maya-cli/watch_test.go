@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/if1live/maya"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProject records jobs which are run.
+type fakeProject struct {
+	list []job
+	ran  []string
+}
+
+func (p *fakeProject) run() error           { return nil }
+func (p *fakeProject) jobs() ([]job, error) { return p.list, nil }
+func (p *fakeProject) runJob(j job) error   { p.ran = append(p.ran, j.src); return nil }
+
+func TestSnapshot_diff(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya-cli")
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.md": "a", "b.md": "b"})
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	c := filepath.Join(dir, "c.md")
+	paths := []string{a, b, c}
+
+	prev := takeSnapshot(paths)
+	assert.Equal(t, map[string]bool{}, takeSnapshot(paths).diff(prev))
+
+	// create
+	writeFiles(t, dir, map[string]string{"c.md": "c"})
+	current := takeSnapshot(paths)
+	assert.Equal(t, map[string]bool{c: true}, current.diff(prev))
+	prev = current
+
+	// modify
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(a, later, later)
+	current = takeSnapshot(paths)
+	assert.Equal(t, map[string]bool{a: true}, current.diff(prev))
+	prev = current
+
+	// delete
+	os.Remove(b)
+	current = takeSnapshot(paths)
+	assert.Equal(t, map[string]bool{b: true}, current.diff(prev))
+	prev = current
+
+	// no longer watched
+	current = takeSnapshot([]string{a, b})
+	assert.Equal(t, map[string]bool{c: true}, current.diff(prev))
+}
+
+func TestDebouncer(t *testing.T) {
+	d := newDebouncer(300 * time.Millisecond)
+	now := time.Now()
+	assert.Nil(t, d.ready(now))
+
+	// rapid successive writes are collected until files are quiet
+	d.add(map[string]bool{"a.md": true}, now)
+	d.add(map[string]bool{}, now.Add(100*time.Millisecond))
+	d.add(map[string]bool{"a.md": true}, now.Add(200*time.Millisecond))
+	d.add(map[string]bool{"b.md": true}, now.Add(300*time.Millisecond))
+	assert.Nil(t, d.ready(now.Add(400*time.Millisecond)))
+	assert.Nil(t, d.ready(now.Add(599*time.Millisecond)))
+	assert.Equal(t, map[string]bool{"a.md": true, "b.md": true}, d.ready(now.Add(600*time.Millisecond)))
+
+	// pending files are cleared
+	assert.Nil(t, d.ready(now.Add(time.Second)))
+}
+
+func TestWatcher_poll(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya-cli")
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.md": "", "b.png": "", "dep.py": "", "new.py": ""})
+	a := filepath.Join(dir, "a.md")
+	png := filepath.Join(dir, "b.png")
+	dep := filepath.Join(dir, "dep.py")
+	jobs := []job{{src: a, build: true}, {src: png}}
+
+	w := newWatcher(&fakeProject{}, time.Second, 0)
+	// every source is changed at the first poll
+	assert.Equal(t, map[string]bool{a: true, png: true}, w.poll(jobs))
+	assert.Equal(t, map[string]bool{}, w.poll(jobs))
+
+	// new dependencies are found by build and not changed
+	w.inputs[a] = []string{dep}
+	assert.Equal(t, map[string]bool{}, w.poll(jobs))
+
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(dep, later, later)
+	assert.Equal(t, map[string]bool{dep: true}, w.poll(jobs))
+
+	os.Remove(dep)
+	assert.Equal(t, map[string]bool{dep: true}, w.poll(jobs))
+
+	// new source is changed and removed source is deleted
+	newSource := filepath.Join(dir, "new.py")
+	jobs = []job{{src: a, build: true}, {src: newSource}}
+	assert.Equal(t, map[string]bool{png: true, newSource: true}, w.poll(jobs))
+}
+
+func TestWatcher_rebuild(t *testing.T) {
+	_mode = maya.ModeEmpty
+	dir, _ := ioutil.TempDir("", "maya-cli")
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.md":    "~~~maya:view\nfile=demo.py\n~~~\n",
+		"b.md":    "hello",
+		"demo.py": "print(1)",
+		"c.png":   "",
+	})
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	png := filepath.Join(dir, "c.png")
+	demo := filepath.Join(dir, "demo.py")
+
+	p := &fakeProject{list: []job{{src: a, build: true}, {src: b, build: true}, {src: png}}}
+	w := newWatcher(p, time.Second, 0)
+	w.rebuild(p.list, w.poll(p.list), false)
+	assert.Equal(t, []string{a, b, png}, p.ran)
+	assert.Equal(t, []string{demo}, w.inputs[a])
+
+	// only the document which reads the dependency is rebuilt
+	p.ran = nil
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(demo, later, later)
+	w.rebuild(p.list, w.poll(p.list), true)
+	assert.Equal(t, []string{a}, p.ran)
+
+	p.ran = nil
+	os.Chtimes(png, later, later)
+	w.rebuild(p.list, w.poll(p.list), true)
+	assert.Equal(t, []string{png}, p.ran)
+}
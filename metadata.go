@@ -2,9 +2,9 @@ package maya
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"regexp"
-	"strconv"
 	"strings"
 	"text/template"
 	"unicode/utf8"
@@ -14,61 +14,56 @@ import (
 )
 
 const (
-	ModePelican = "pelican"
-	ModeHugo    = "hugo"
-	ModeEmpty   = "empty"
+	ModePelican  = "pelican"
+	ModeHugo     = "hugo"
+	ModeJekyll   = "jekyll"
+	ModeHexo     = "hexo"
+	ModeZola     = "zola"
+	ModeEleventy = "eleventy"
+	ModeGatsby   = "gatsby"
+	// ModeJSON writes JSON front matter which hugo reads.
+	ModeJSON  = "json"
+	ModeEmpty = "empty"
 )
 
-const templateHugo = `
-+++
-{{range $_,$elem := .Table}}
-{{if $elem.IsListValue}}
-{{$elem.Key}} = ["{{join $elem.ListValue "\", \""}}"]
-{{else}}
-{{$elem.Key}} = "{{escape $elem.Value}}"
-{{end}}
-{{end}}
-+++
-`
-
-const templatePelican = `
-{{range $_,$elem := .Table}}
-{{if $elem.IsListValue}}
-{{title $elem.Key}}: {{join $elem.ListValue ", "}}
-{{else}}
-{{title $elem.Key}}: {{$elem.Value}}
-{{end}}
-{{end}}
-`
-
-func getTemplateHugo() string {
-	return strings.TrimLeft(templateHugo, " ")
-}
-func getTemplatePelican() string {
-	return strings.TrimLeft(templatePelican, " ")
-}
-func getTemplateEmpty() string {
-	return ""
-}
-
-type MetadataKeyValue struct {
-	Key string
-
-	isList    bool
-	singleVal string
-	multiVal  []string
-}
+// formats of front matter in the source.
+const (
+	MetadataYAML = "yaml"
+	MetadataTOML = "toml"
+	MetadataJSON = "json"
+)
 
-func (kv *MetadataKeyValue) IsListValue() bool {
-	return kv.isList
+// metadataFormat writes front matter of builtin modes with encoder.
+// open and close are delimiters of front matter.
+type metadataFormat struct {
+	open   string
+	close  string
+	encode func([]MetadataKeyValue) (string, error)
 }
 
-func (kv *MetadataKeyValue) Value() string {
-	return kv.singleVal
+var metadataFormats = map[string]metadataFormat{
+	ModePelican:  {"", "", encodePelican},
+	ModeHugo:     {"+++", "+++", encodeTOML},
+	ModeJekyll:   {"---", "---", encodeYAML},
+	ModeHexo:     {"---", "---", encodeYAML},
+	ModeZola:     {"+++", "+++", encodeTOML},
+	ModeEleventy: {"---", "---", encodeYAML},
+	ModeGatsby:   {"---", "---", encodeYAML},
+	ModeJSON:     {"", "", encodeJSON},
+	ModeEmpty: {"", "", func([]MetadataKeyValue) (string, error) {
+		return "", nil
+	}},
 }
 
-func (kv *MetadataKeyValue) ListValue() []string {
-	return kv.multiVal
+func (f *metadataFormat) format(entries []MetadataKeyValue) (string, error) {
+	body, err := f.encode(entries)
+	if err != nil {
+		return "", err
+	}
+	if f.open == "" {
+		return strings.TrimSuffix(body, "\n"), nil
+	}
+	return f.open + "\n" + body + f.close, nil
 }
 
 type ArticleMetadata struct {
@@ -78,83 +73,148 @@ type ArticleMetadata struct {
 type MetadataTemplateLoader struct {
 	texts     map[string]string
 	templates map[string]*template.Template
+	files     map[string]string
 }
 
-func NewMetadata(text string) *ArticleMetadata {
-	m := yaml.MapSlice{}
-	err := yaml.Unmarshal([]byte(text), &m)
-	if err != nil {
-		panic(err)
+func NewMetadata(text string) (*ArticleMetadata, error) {
+	v := metadataValue{}
+	if err := yaml.Unmarshal([]byte(text), &v); err != nil {
+		return nil, err
 	}
-	dict := NewDict(m)
-	keys := dict.GetStrKeys()
-
-	table := []MetadataKeyValue{}
-	for _, key := range keys {
-		pair := MetadataKeyValue{
-			Key: key,
-		}
-		switch dict.GetValueType(key) {
-		case valueTypeStr:
-			pair.isList = false
-			val, _ := dict.GetStr(key)
-			pair.singleVal = val
-			break
-
-		case valueTypeInt:
-			pair.isList = false
-			tmp, _ := dict.GetInt(key)
-			str := strconv.Itoa(tmp)
-			pair.singleVal = str
-			break
-
-		case valueTypeStrList:
-			pair.isList = true
-			val, _ := dict.GetStrList(key)
-			pair.multiVal = val
-			break
-
-		case valueTypeIntList:
-			pair.isList = true
-			tmp, _ := dict.GetIntList(key)
-			val := make([]string, len(tmp))
-			for i, num := range tmp {
-				val[i] = strconv.Itoa(num)
-			}
-			pair.multiVal = val
-		}
-		table = append(table, pair)
+	table, ok := v.value.([]MetadataKeyValue)
+	if v.value != nil && !ok {
+		return nil, fmt.Errorf("metadata is not a map: %v", v.value)
+	}
+	if table == nil {
+		table = []MetadataKeyValue{}
 	}
-
 	return &ArticleMetadata{
 		Table: table,
+	}, nil
+}
+
+// ParseMetadata parses front matter written in format, which is yaml, toml or json.
+// empty format is yaml.
+func ParseMetadata(text string, format string) (*ArticleMetadata, error) {
+	var table []MetadataKeyValue
+	var err error
+	switch format {
+	case "", MetadataYAML:
+		return NewMetadata(text)
+	case MetadataTOML:
+		table, err = decodeTOML(text)
+	case MetadataJSON:
+		table, err = decodeJSON(text)
+	default:
+		return nil, fmt.Errorf("unknown metadata format: %s", format)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return &ArticleMetadata{
+		Table: table,
+	}, nil
 }
 
 func (m *ArticleMetadata) Preprocess(mode string) {
 	type Func func(*ArticleMetadata)
 	funcs := map[string]Func{
-		"hugo": preprocessHugo,
+		ModeHugo:     preprocessHugo,
+		ModeJekyll:   preprocessJekyll,
+		ModeHexo:     preprocessHexo,
+		ModeZola:     preprocessZola,
+		ModeEleventy: preprocessEleventy,
+		ModeGatsby:   preprocessGatsby,
 	}
 	if fn, ok := funcs[mode]; ok {
 		fn(m)
 	}
 }
 
-func preprocessHugo(m *ArticleMetadata) {
-	type Func func(string) string
-	funcs := map[string]Func{
-		"date": preprocessHugo_date,
+// find returns index of key in Table. -1 if not exist.
+func (m *ArticleMetadata) find(key string) int {
+	for i, t := range m.Table {
+		if t.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// rename changes key. nothing happens if the new key exists.
+func (m *ArticleMetadata) rename(from, to string) {
+	i := m.find(from)
+	if i < 0 || m.find(to) >= 0 {
+		return
+	}
+	m.Table[i].Key = to
+}
+
+// remove deletes key and returns the removed value.
+func (m *ArticleMetadata) remove(key string) (MetadataKeyValue, bool) {
+	i := m.find(key)
+	if i < 0 {
+		return MetadataKeyValue{}, false
 	}
+	kv := m.Table[i]
+	m.Table = append(m.Table[:i:i], m.Table[i+1:]...)
+	return kv, true
+}
+
+// set replaces value of the key or appends it.
+func (m *ArticleMetadata) set(kv MetadataKeyValue) {
+	if i := m.find(kv.Key); i >= 0 {
+		m.Table[i] = kv
+		return
+	}
+	m.Table = append(m.Table, kv)
+}
 
+// mapValues replaces string values of keys with results of funcs.
+func (m *ArticleMetadata) mapValues(funcs map[string]func(string) string) {
 	for i, t := range m.Table {
-		if fn, ok := funcs[t.Key]; ok {
-			t.singleVal = fn(t.singleVal)
-			m.Table[i] = t
+		fn, ok := funcs[t.Key]
+		if !ok {
+			continue
+		}
+		if val, ok := t.value.(string); ok {
+			m.Table[i].value = fn(val)
 		}
 	}
 }
 
+// values returns list value of key. single value becomes a list.
+func (m *ArticleMetadata) values(key string) []interface{} {
+	i := m.find(key)
+	if i < 0 {
+		return nil
+	}
+	if list, ok := m.Table[i].value.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{m.Table[i].value}
+}
+
+// draft removes status and reports whether it was draft, like pelican.
+func (m *ArticleMetadata) draft() bool {
+	status, ok := m.remove("status")
+	return ok && status.value == "draft"
+}
+
+func preprocessHugo(m *ArticleMetadata) {
+	m.mapValues(map[string]func(string) string{
+		"date": preprocessHugo_date,
+	})
+	if i := m.find("date"); i >= 0 {
+		if d, ok := m.Table[i].value.(metadataDate); ok {
+			m.Table[i].value = d.Time
+		}
+	}
+	if m.draft() {
+		m.set(MetadataKeyValue{Key: "draft", value: true})
+	}
+}
+
 func preprocessHugo_date(val string) string {
 	// to support date-only format
 	// YYYY-MM-DD => YYYY-MM-DDT00:00:00+00:00
@@ -165,47 +225,185 @@ func preprocessHugo_date(val string) string {
 	return val
 }
 
-func NewTemplateLoader() MetadataTemplateLoader {
-	loader := MetadataTemplateLoader{
-		texts:     map[string]string{},
-		templates: map[string]*template.Template{},
+var dateTimeRe = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})[ T](\d{2}:\d{2})(:\d{2})?$`)
+
+// dateTimeWith adds seconds to datetime of pelican like 2010-12-03 10:20
+// and joins date and time with sep. other values are not changed.
+func dateTimeWith(sep string) func(string) string {
+	return func(val string) string {
+		m := dateTimeRe.FindStringSubmatch(val)
+		if m == nil {
+			return val
+		}
+		seconds := m[3]
+		if seconds == "" {
+			seconds = ":00"
+		}
+		return m[1] + sep + m[2] + seconds
 	}
+}
 
-	targets := []struct {
-		mode string
-		text string
-	}{
-		{ModePelican, getTemplatePelican()},
-		{ModeHugo, getTemplateHugo()},
-		{ModeEmpty, getTemplateEmpty()},
+func preprocessJekyll(m *ArticleMetadata) {
+	m.mapValues(map[string]func(string) string{
+		"date":     dateTimeWith(" "),
+		"modified": dateTimeWith(" "),
+	})
+	m.rename("modified", "last_modified_at")
+	m.rename("summary", "excerpt")
+	m.rename("category", "categories")
+	if m.draft() {
+		m.set(MetadataKeyValue{Key: "published", value: false})
 	}
+}
 
-	for _, target := range targets {
-		loader.RegisterTemplate(target.mode, target.text)
+func preprocessHexo(m *ArticleMetadata) {
+	m.mapValues(map[string]func(string) string{
+		"date":     dateTimeWith(" "),
+		"modified": dateTimeWith(" "),
+	})
+	m.rename("modified", "updated")
+	m.rename("summary", "excerpt")
+	m.rename("category", "categories")
+	if m.draft() {
+		m.set(MetadataKeyValue{Key: "published", value: false})
 	}
+}
 
-	return loader
+// zolaKeys are front matter of zola pages. other keys belong to [extra].
+var zolaKeys = map[string]bool{
+	"title": true, "description": true, "date": true, "updated": true,
+	"weight": true, "draft": true, "slug": true, "path": true,
+	"aliases": true, "authors": true, "in_search_index": true, "template": true,
 }
 
-func (l *MetadataTemplateLoader) RegisterFile(mode, filepath string) bool {
+func preprocessZola(m *ArticleMetadata) {
+	m.mapValues(map[string]func(string) string{
+		"date":     dateTimeWith("T"),
+		"modified": dateTimeWith("T"),
+	})
+	m.rename("modified", "updated")
+	m.rename("summary", "description")
+	m.rename("category", "categories")
+	if m.draft() {
+		m.set(MetadataKeyValue{Key: "draft", value: true})
+	}
+	if authors := m.values("authors"); authors != nil {
+		// pelican writes authors separated by comma
+		list := []interface{}{}
+		for _, a := range authors {
+			if text, ok := a.(string); ok {
+				for _, name := range splitList(text) {
+					list = append(list, name)
+				}
+			} else {
+				list = append(list, a)
+			}
+		}
+		m.set(MetadataKeyValue{Key: "authors", value: list})
+	}
+
+	table := []MetadataKeyValue{}
+	taxonomies := []MetadataKeyValue{}
+	extra := []MetadataKeyValue{}
+	for _, t := range m.Table {
+		switch {
+		case t.Key == "tags" || t.Key == "categories":
+			taxonomies = append(taxonomies, MetadataKeyValue{Key: t.Key, value: m.values(t.Key)})
+		case t.Key == "taxonomies" && t.IsMapValue():
+			taxonomies = append(taxonomies, t.MapValue()...)
+		case t.Key == "extra" && t.IsMapValue():
+			extra = append(extra, t.MapValue()...)
+		case !zolaKeys[t.Key]:
+			extra = append(extra, t)
+		default:
+			table = append(table, t)
+		}
+	}
+	if len(taxonomies) > 0 {
+		table = append(table, MetadataKeyValue{Key: "taxonomies", value: taxonomies})
+	}
+	if len(extra) > 0 {
+		table = append(table, MetadataKeyValue{Key: "extra", value: extra})
+	}
+	m.Table = table
+}
+
+func preprocessEleventy(m *ArticleMetadata) {
+	m.mapValues(map[string]func(string) string{
+		"date":     dateTimeWith("T"),
+		"modified": dateTimeWith("T"),
+	})
+	m.rename("summary", "description")
+	// collections of eleventy are made from tags
+	if categories := m.values("category"); categories != nil {
+		m.remove("category")
+		tags := append(append([]interface{}{}, m.values("tags")...), categories...)
+		m.set(MetadataKeyValue{Key: "tags", value: tags})
+	}
+	if i := m.find("slug"); i >= 0 && m.find("permalink") < 0 {
+		m.set(MetadataKeyValue{Key: "permalink", value: "/" + m.Table[i].Value() + "/"})
+	}
+	if m.draft() {
+		m.set(MetadataKeyValue{Key: "permalink", value: false})
+		m.set(MetadataKeyValue{Key: "eleventyExcludeFromCollections", value: true})
+	}
+}
+
+func preprocessGatsby(m *ArticleMetadata) {
+	m.mapValues(map[string]func(string) string{
+		"date":     dateTimeWith("T"),
+		"modified": dateTimeWith("T"),
+	})
+	m.rename("summary", "description")
+	if m.draft() {
+		m.set(MetadataKeyValue{Key: "draft", value: true})
+	}
+}
+
+// NewTemplateLoader returns loader of builtin modes.
+// builtin modes are written by encoders unless templates are registered.
+func NewTemplateLoader() MetadataTemplateLoader {
+	return MetadataTemplateLoader{
+		texts:     map[string]string{},
+		templates: map[string]*template.Template{},
+		files:     map[string]string{},
+	}
+}
+
+func (l *MetadataTemplateLoader) RegisterFile(mode, filepath string) error {
 	text, err := l.readFile(filepath)
 	if err != nil {
-		return false
+		return err
+	}
+	if err := l.RegisterTemplate(mode, text); err != nil {
+		return fmt.Errorf("%s: %v", filepath, err)
 	}
-	ok := l.RegisterTemplate(mode, text)
+	l.files[mode] = filepath
 
 	log := logging.MustGetLogger("maya")
 	log.Infof("Metadata Template Load Success [%s] %s", mode, filepath)
-	return ok
+	return nil
 }
 
-func (l *MetadataTemplateLoader) RegisterTemplate(mode, text string) bool {
+func (l *MetadataTemplateLoader) RegisterTemplate(mode, text string) error {
 	funcMap := l.createFuncMap()
+	t, err := template.New(mode).Funcs(funcMap).Parse(text)
+	if err != nil {
+		return err
+	}
 	l.texts[mode] = text
-	l.templates[mode] = template.Must(
-		template.New(mode).Funcs(funcMap).Parse(l.texts[mode]),
-	)
-	return true
+	l.templates[mode] = t
+	delete(l.files, mode)
+	return nil
+}
+
+// Files returns template files registered by RegisterFile. mode -> file path
+func (l *MetadataTemplateLoader) Files() map[string]string {
+	files := map[string]string{}
+	for mode, path := range l.files {
+		files[mode] = path
+	}
+	return files
 }
 
 func makeSeperator(text string, sep string) string {
@@ -253,20 +451,31 @@ func (l *MetadataTemplateLoader) createFuncMap() template.FuncMap {
 		"seperator": makeSeperator,
 		"isString":  isString,
 		"escape":    escape,
+		"toml":      encodeTOML,
+		"yaml":      encodeYAML,
+		"json":      encodeJSON,
 	}
 }
 
-func (l *MetadataTemplateLoader) Execute(metadata *ArticleMetadata, mode string) string {
+func (l *MetadataTemplateLoader) Execute(metadata *ArticleMetadata, mode string) (string, error) {
+	// preprocess must not change table of metadata
 	metadataClone := *metadata
+	metadataClone.Table = append([]MetadataKeyValue{}, metadata.Table...)
 	metadataClone.Preprocess(mode)
 
+	// registered template replaces encoder of builtin mode
 	t := l.templates[mode]
 	if t == nil {
-		msg := "Unknown document mode : " + mode
-		panic(msg)
+		f, ok := metadataFormats[mode]
+		if !ok {
+			return "", fmt.Errorf("unknown document mode: %s", mode)
+		}
+		return f.format(metadataClone.Table)
 	}
 	var b bytes.Buffer
-	t.Execute(&b, &metadataClone)
+	if err := t.Execute(&b, &metadataClone); err != nil {
+		return "", err
+	}
 	text := string(b.Bytes())
 	lines := strings.Split(text, "\n")
 
@@ -279,7 +488,7 @@ func (l *MetadataTemplateLoader) Execute(metadata *ArticleMetadata, mode string)
 		}
 	}
 
-	return strings.Join(result, "\n")
+	return strings.Join(result, "\n"), nil
 }
 
 func (l *MetadataTemplateLoader) readFile(filepath string) (string, error) {
@@ -1,10 +1,18 @@
 package maya
 
 import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/BurntSushi/toml"
 	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func TestExecute(t *testing.T) {
@@ -16,15 +24,16 @@ tags: [foo, bar]
 slug: slug-1
 status: draft
 `
-	metadata := NewMetadata(metadataText)
+	metadata, err := NewMetadata(metadataText)
+	assert.Nil(t, err)
 	loader := NewTemplateLoader()
 
 	cases := []struct {
-		actual   string
+		mode     string
 		expected string
 	}{
 		{
-			loader.Execute(metadata, ModePelican),
+			ModePelican,
 			strings.Trim(`
 Title: 제목
 Subtitle: subtitle-1
@@ -35,22 +44,159 @@ Status: draft
 `, "\n"),
 		},
 		{
-			loader.Execute(metadata, ModeHugo),
+			ModeHugo,
 			strings.Trim(`
 +++
 title = "제목"
 subtitle = "subtitle-1"
-date = "2016-02-20T00:00:00+00:00"
+date = 2016-02-20T00:00:00Z
 tags = ["foo", "bar"]
 slug = "slug-1"
-status = "draft"
+draft = true
 +++
 `, "\n"),
 		},
 	}
 	for _, c := range cases {
-		assert.Equal(t, c.expected, c.actual)
+		actual, err := loader.Execute(metadata, c.mode)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, actual)
 	}
+
+	_, err = loader.Execute(metadata, "invalid-mode")
+	assert.NotNil(t, err)
+}
+
+var update = flag.Bool("update", false, "update golden files of testdata")
+
+// TestExecute_golden compares header of every mode with testdata/metadata/<mode>.golden
+func TestExecute_golden(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "metadata", "post.yaml"))
+	assert.Nil(t, err)
+	metadata, err := NewMetadata(string(data))
+	assert.Nil(t, err)
+	loader := NewTemplateLoader()
+
+	modes := []string{ModePelican, ModeHugo, ModeJekyll, ModeHexo, ModeZola, ModeEleventy, ModeGatsby, ModeJSON}
+	for _, mode := range modes {
+		actual, err := loader.Execute(metadata, mode)
+		assert.Nil(t, err, mode)
+
+		golden := filepath.Join("testdata", "metadata", mode+".golden")
+		if *update {
+			assert.Nil(t, ioutil.WriteFile(golden, []byte(actual+"\n"), 0644))
+		}
+		expected, err := ioutil.ReadFile(golden)
+		assert.Nil(t, err, mode)
+		assert.Equal(t, string(expected), actual+"\n", mode)
+	}
+
+	// preprocess does not change metadata
+	actual, err := loader.Execute(metadata, ModeEmpty)
+	assert.Nil(t, err)
+	assert.Equal(t, "", actual)
+	assert.Len(t, metadata.Table, 16)
+	assert.Equal(t, "status", metadata.Table[10].Key)
+}
+
+// TestExecute_roundTrip parses generated headers again.
+func TestExecute_roundTrip(t *testing.T) {
+	post, err := ioutil.ReadFile(filepath.Join("testdata", "metadata", "post.yaml"))
+	assert.Nil(t, err)
+	title := `back\slash "quoted" 'single' #hash: colon`
+	summary := "line1\nline2\ttab\x01 ctrl"
+	tricky := "title: " + strconv.Quote(title) + "\nsummary: " + strconv.Quote(summary) + "\n\"key with space\": \"[not a list]\""
+	loader := NewTemplateLoader()
+
+	for _, text := range []string{string(post), tricky} {
+		metadata, err := NewMetadata(text)
+		assert.Nil(t, err)
+		for _, mode := range []string{ModeHugo, ModeJekyll, ModeHexo, ModeZola, ModeEleventy, ModeGatsby, ModeJSON} {
+			header, err := loader.Execute(metadata, mode)
+			assert.Nil(t, err, mode)
+
+			f := metadataFormats[mode]
+			body := strings.TrimSuffix(strings.TrimPrefix(header, f.open), f.close)
+			var parsed interface{}
+			switch f.open {
+			case "+++":
+				_, err = toml.Decode(body, &parsed)
+			case "---":
+				err = yaml.Unmarshal([]byte(body), &parsed)
+			default:
+				err = json.Unmarshal([]byte(body), &parsed)
+			}
+			assert.Nil(t, err, header)
+			if text == tricky {
+				assert.True(t, containsValue(parsed, title), header)
+				assert.True(t, containsValue(parsed, summary), header)
+				assert.True(t, containsValue(parsed, "[not a list]"), header)
+			}
+		}
+	}
+
+	metadata, _ := NewMetadata("title: " + strconv.Quote(title) + "\nsummary: " + strconv.Quote(summary))
+	header, err := loader.Execute(metadata, ModePelican)
+	assert.Nil(t, err)
+	assert.Equal(t, "Title: "+title+"\nSummary: line1\n    line2\ttab\x01 ctrl", header)
+}
+
+// containsValue reports whether parsed document has string value.
+func containsValue(parsed interface{}, value string) bool {
+	switch v := parsed.(type) {
+	case string:
+		return v == value
+	case []interface{}:
+		for _, el := range v {
+			if containsValue(el, value) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, el := range v {
+			if containsValue(el, value) {
+				return true
+			}
+		}
+	case map[interface{}]interface{}:
+		for _, el := range v {
+			if containsValue(el, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestExecute_draft(t *testing.T) {
+	metadata, err := NewMetadata("title: hello\nslug: hello\ncategory: go\nstatus: published")
+	assert.Nil(t, err)
+	loader := NewTemplateLoader()
+
+	cases := []struct {
+		mode     string
+		expected string
+	}{
+		{ModeJekyll, "---\ntitle: hello\nslug: hello\ncategories: go\n---"},
+		{ModeEleventy, "---\ntitle: hello\nslug: hello\ntags:\n- go\npermalink: /hello/\n---"},
+		{ModeZola, "+++\ntitle = \"hello\"\nslug = \"hello\"\n[taxonomies]\ncategories = [\"go\"]\n+++"},
+	}
+	for _, c := range cases {
+		actual, err := loader.Execute(metadata, c.mode)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, actual, c.mode)
+	}
+}
+
+func TestNewMetadata_invalid(t *testing.T) {
+	_, err := NewMetadata("title: [hello")
+	assert.NotNil(t, err)
+}
+
+func TestRegisterTemplate_invalid(t *testing.T) {
+	loader := NewTemplateLoader()
+	assert.NotNil(t, loader.RegisterTemplate("invalid", "{{.Table"))
+	assert.NotNil(t, loader.RegisterFile("invalid", "not-exist.tpl"))
 }
 
 func Test_makeSeperator(t *testing.T) {
@@ -97,3 +243,23 @@ func Test_escape(t *testing.T) {
 		assert.Equal(t, c.expected, escape(c.input))
 	}
 }
+
+func TestRegisterFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "maya")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString("{{range $_,$elem := .Table}}{{$elem.Key}}={{$elem.Value}}{{end}}")
+	f.Close()
+
+	loader := NewTemplateLoader()
+	assert.Nil(t, loader.RegisterFile("custom", f.Name()))
+	assert.Equal(t, map[string]string{"custom": f.Name()}, loader.Files())
+
+	metadata, _ := NewMetadata("title: hello")
+	actual, err := loader.Execute(metadata, "custom")
+	assert.Nil(t, err)
+	assert.Equal(t, "title=hello", actual)
+
+	loader.RegisterTemplate("custom", "")
+	assert.Equal(t, map[string]string{}, loader.Files())
+}
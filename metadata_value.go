@@ -0,0 +1,636 @@
+package maya
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type MetadataKeyValue struct {
+	Key string
+
+	// value is nil, string, int, int64, uint64, float64, bool, time.Time, metadataDate,
+	// []interface{} of values or []MetadataKeyValue of map.
+	value interface{}
+}
+
+func (kv *MetadataKeyValue) IsListValue() bool {
+	_, ok := kv.value.([]interface{})
+	return ok
+}
+
+func (kv *MetadataKeyValue) IsMapValue() bool {
+	_, ok := kv.value.([]MetadataKeyValue)
+	return ok
+}
+
+// IsLiteral reports whether Value is a number, boolean or time
+// which is written without quotes.
+func (kv *MetadataKeyValue) IsLiteral() bool {
+	return isLiteral(kv.value)
+}
+
+// Value returns the value as string. empty if the value is list or map.
+func (kv *MetadataKeyValue) Value() string {
+	return formatScalar(kv.value)
+}
+
+// ListValue returns elements of list as string.
+func (kv *MetadataKeyValue) ListValue() []string {
+	list, ok := kv.value.([]interface{})
+	if !ok {
+		return nil
+	}
+	val := make([]string, len(list))
+	for i, el := range list {
+		val[i] = formatScalar(el)
+	}
+	return val
+}
+
+// MapValue returns entries of map in the order of source.
+func (kv *MetadataKeyValue) MapValue() []MetadataKeyValue {
+	m, _ := kv.value.([]MetadataKeyValue)
+	return m
+}
+
+// Interface returns the typed value.
+// map is []MetadataKeyValue to keep the order of keys.
+// date without time is time.Time of midnight in UTC.
+func (kv *MetadataKeyValue) Interface() interface{} {
+	return exportValue(kv.value)
+}
+
+func exportValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case metadataDate:
+		return v.Time
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, el := range v {
+			list[i] = exportValue(el)
+		}
+		return list
+	}
+	return v
+}
+
+// metadataDate is date without time like 2016-02-20.
+// it is kept apart from time.Time to be written as date again.
+type metadataDate struct {
+	time.Time
+}
+
+func (d metadataDate) String() string {
+	return d.Format("2006-01-02")
+}
+
+var dateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// metadataValue decodes yaml with types.
+// yaml.v2 decodes unquoted timestamps into interface{} as string,
+// metadataValue decodes them as time.Time, or metadataDate if there is no time.
+type metadataValue struct {
+	value interface{}
+}
+
+func (v *metadataValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	switch raw.(type) {
+	case string:
+		var t time.Time
+		if err := unmarshal(&t); err == nil {
+			if dateRe.MatchString(raw.(string)) {
+				v.value = metadataDate{t}
+			} else {
+				v.value = t
+			}
+			return nil
+		}
+		v.value = raw
+
+	case []interface{}:
+		values := []metadataValue{}
+		if err := unmarshal(&values); err != nil {
+			return err
+		}
+		list := make([]interface{}, len(values))
+		for i, el := range values {
+			list[i] = el.value
+		}
+		v.value = list
+
+	case map[interface{}]interface{}:
+		// MapSlice keeps the order and the map keeps the types
+		keys := yaml.MapSlice{}
+		if err := unmarshal(&keys); err != nil {
+			return err
+		}
+		values := map[interface{}]metadataValue{}
+		if err := unmarshal(&values); err != nil {
+			return err
+		}
+		entries := []MetadataKeyValue{}
+		for _, item := range keys {
+			entries = append(entries, MetadataKeyValue{
+				Key:   fmt.Sprint(item.Key),
+				value: values[item.Key].value,
+			})
+		}
+		v.value = entries
+
+	default:
+		v.value = raw
+	}
+	return nil
+}
+
+// decodeTOML decodes TOML front matter in the order of keys in the document.
+// local dates are midnight of UTC and other local times are kept as string.
+func decodeTOML(text string) ([]MetadataKeyValue, error) {
+	m := map[string]interface{}{}
+	md, err := toml.Decode(text, &m)
+	if err != nil {
+		return nil, err
+	}
+	order := map[string]int{}
+	for i, key := range md.Keys() {
+		if _, ok := order[key.String()]; !ok {
+			order[key.String()] = i
+		}
+	}
+	return tomlEntries(m, nil, order), nil
+}
+
+func tomlEntries(m map[string]interface{}, path toml.Key, order map[string]int) []MetadataKeyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	// keys of inline tables are not in order, they are sorted by name
+	sort.Strings(keys)
+	index := func(k string) int {
+		if i, ok := order[append(path[:len(path):len(path)], k).String()]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return index(keys[i]) < index(keys[j])
+	})
+
+	entries := []MetadataKeyValue{}
+	for _, k := range keys {
+		entries = append(entries, MetadataKeyValue{
+			Key:   k,
+			value: fromTOML(m[k], append(path[:len(path):len(path)], k), order),
+		})
+	}
+	return entries
+}
+
+func fromTOML(v interface{}, path toml.Key, order map[string]int) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return tomlEntries(v, path, order)
+	case []map[string]interface{}:
+		list := make([]interface{}, len(v))
+		for i, el := range v {
+			list[i] = tomlEntries(el, path, order)
+		}
+		return list
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, el := range v {
+			list[i] = fromTOML(el, path, order)
+		}
+		return list
+	case int64:
+		if int64(int(v)) == v {
+			return int(v)
+		}
+	case time.Time:
+		switch v.Location().String() {
+		case "date-local":
+			return metadataDate{time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC)}
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999")
+		case "time-local":
+			return v.Format("15:04:05.999999999")
+		}
+	}
+	return v
+}
+
+// decodeJSON decodes JSON front matter in the order of keys in the document.
+func decodeJSON(text string) ([]MetadataKeyValue, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid data after metadata")
+	}
+	table, ok := v.([]MetadataKeyValue)
+	if !ok {
+		return nil, fmt.Errorf("metadata is not a map: %v", v)
+	}
+	return table, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '{' {
+			entries := []MetadataKeyValue{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, MetadataKeyValue{Key: key.(string), value: value})
+			}
+			_, err := dec.Token()
+			return entries, err
+		}
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	case json.Number:
+		if n, err := strconv.ParseInt(string(tok), 10, 0); err == nil {
+			return int(n), nil
+		}
+		return tok.Float64()
+	}
+	return tok, nil
+}
+
+func isLiteral(v interface{}) bool {
+	switch v.(type) {
+	case int, int64, uint64, float64, bool, time.Time, metadataDate:
+		return true
+	}
+	return false
+}
+
+// formatScalar returns text of value. empty if the value is list or map.
+func formatScalar(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int, int64, uint64, bool:
+		return fmt.Sprint(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case metadataDate:
+		return v.String()
+	}
+	return ""
+}
+
+// encodeTOML writes entries as TOML in the order of entries.
+// maps and lists of maps are written as tables after other keys.
+// nil is omitted because TOML has no null.
+func encodeTOML(entries []MetadataKeyValue) (string, error) {
+	var buf bytes.Buffer
+	if err := writeTOMLTable(&buf, "", entries); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeTOMLTable(buf *bytes.Buffer, name string, entries []MetadataKeyValue) error {
+	tables := []MetadataKeyValue{}
+	for _, kv := range entries {
+		switch {
+		case kv.value == nil:
+		case kv.IsMapValue() || isMapList(kv.value):
+			tables = append(tables, kv)
+		default:
+			v := map[string]interface{}{kv.Key: tomlValue(kv.value)}
+			if err := toml.NewEncoder(buf).Encode(v); err != nil {
+				return fmt.Errorf("%s: %v", kv.Key, err)
+			}
+		}
+	}
+	for _, kv := range tables {
+		key, err := tomlKey(kv.Key)
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			key = name + "." + key
+		}
+		if m, ok := kv.value.([]MetadataKeyValue); ok {
+			fmt.Fprintf(buf, "[%s]\n", key)
+			if err := writeTOMLTable(buf, key, m); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, el := range kv.value.([]interface{}) {
+			fmt.Fprintf(buf, "[[%s]]\n", key)
+			if err := writeTOMLTable(buf, key, el.([]MetadataKeyValue)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tomlKey returns key quoted by TOML encoder if it is not a bare key.
+func tomlKey(key string) (string, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(map[string]int{key: 0}); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), " = 0\n"), nil
+}
+
+// isMapList reports whether v is a non-empty list of maps, which is array of tables.
+func isMapList(v interface{}) bool {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	for _, el := range list {
+		if _, ok := el.([]MetadataKeyValue); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlLocalDate is the location which toml package writes time in as local date.
+// it is not exported, so it is taken from a decoded date.
+var tomlLocalDate = func() *time.Location {
+	v := map[string]interface{}{}
+	toml.Decode("date = 2016-02-20", &v)
+	return v["date"].(time.Time).Location()
+}()
+
+// tomlValue converts maps to map[string]interface{} and removes nil from lists.
+// dates are written as local dates.
+func tomlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case metadataDate:
+		return time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, tomlLocalDate)
+	case []interface{}:
+		list := []interface{}{}
+		for _, el := range v {
+			if el != nil {
+				list = append(list, tomlValue(el))
+			}
+		}
+		return list
+	case []MetadataKeyValue:
+		m := map[string]interface{}{}
+		for _, kv := range v {
+			if kv.value != nil {
+				m[kv.Key] = tomlValue(kv.value)
+			}
+		}
+		return m
+	}
+	return v
+}
+
+// encodeYAML writes entries as YAML in the order of entries.
+// yaml.v2 writes time as RFC3339 and quotes date like "2016-02-20",
+// so blocks and dates are written here and yaml.v2 writes keys and other scalars.
+func encodeYAML(entries []MetadataKeyValue) (string, error) {
+	var buf bytes.Buffer
+	if err := writeYAMLMap(&buf, "", entries); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeYAMLMap(buf *bytes.Buffer, indent string, entries []MetadataKeyValue) error {
+	for _, kv := range entries {
+		key, err := yamlKey(kv.Key)
+		if err != nil {
+			return err
+		}
+		switch v := kv.value.(type) {
+		case metadataDate:
+			fmt.Fprintf(buf, "%s%s: %s\n", indent, key, v)
+			continue
+		case []MetadataKeyValue:
+			if len(v) > 0 {
+				fmt.Fprintf(buf, "%s%s:\n", indent, key)
+				if err := writeYAMLMap(buf, indent+"  ", v); err != nil {
+					return err
+				}
+				continue
+			}
+		case []interface{}:
+			if len(v) > 0 {
+				// items of list in map are not indented
+				fmt.Fprintf(buf, "%s%s:\n", indent, key)
+				if err := writeYAMLList(buf, indent, v); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		item := yaml.MapSlice{{Key: kv.Key, Value: yamlValue(kv.value)}}
+		if err := writeYAMLScalar(buf, indent, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeYAMLList(buf *bytes.Buffer, indent string, list []interface{}) error {
+	for _, el := range list {
+		var item bytes.Buffer
+		var err error
+		switch v := el.(type) {
+		case metadataDate:
+			fmt.Fprintf(buf, "%s- %s\n", indent, v)
+			continue
+		case []MetadataKeyValue:
+			err = writeYAMLMap(&item, indent+"  ", v)
+		case []interface{}:
+			err = writeYAMLList(&item, indent+"  ", v)
+		}
+		if err != nil {
+			return err
+		}
+		if item.Len() == 0 {
+			if err := writeYAMLScalar(buf, indent, []interface{}{yamlValue(el)}); err != nil {
+				return err
+			}
+			continue
+		}
+		// the first line of map or list follows the dash
+		buf.WriteString(indent + "- ")
+		buf.Write(item.Bytes()[len(indent)+2:])
+	}
+	return nil
+}
+
+// writeYAMLScalar writes v encoded by yaml.v2 with indent.
+func writeYAMLScalar(buf *bytes.Buffer, indent string, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		if line != "\n" {
+			buf.WriteString(indent)
+		}
+		buf.WriteString(line)
+	}
+	return nil
+}
+
+// yamlKey returns key quoted by yaml.v2 if it is required.
+func yamlKey(key string) (string, error) {
+	data, err := yaml.Marshal(yaml.MapSlice{{Key: key, Value: nil}})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), ": null\n"), nil
+}
+
+// yamlValue converts maps to yaml.MapSlice which keeps the order of keys.
+func yamlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, el := range v {
+			list[i] = yamlValue(el)
+		}
+		return list
+	case []MetadataKeyValue:
+		m := yaml.MapSlice{}
+		for _, kv := range v {
+			m = append(m, yaml.MapItem{Key: kv.Key, Value: yamlValue(kv.value)})
+		}
+		return m
+	}
+	return v
+}
+
+// encodeJSON writes entries as JSON object in the order of entries.
+func encodeJSON(entries []MetadataKeyValue) (string, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, entries); err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return "", err
+	}
+	return out.String() + "\n", nil
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case []interface{}:
+		buf.WriteString("[")
+		for i, el := range v {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if err := writeJSON(buf, el); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+		return nil
+	case []MetadataKeyValue:
+		buf.WriteString("{")
+		for i, kv := range v {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			if err := writeJSON(buf, kv.Key); err != nil {
+				return err
+			}
+			buf.WriteString(":")
+			if err := writeJSON(buf, kv.value); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+		return nil
+	case metadataDate:
+		// JSON has no dates
+		return writeJSON(buf, v.String())
+	}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	// Encode appends newline
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+var pelicanKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// encodePelican writes entries as metadata of pelican markdown reader.
+// lists are separated by comma and lines of multi-line value are indented.
+// maps are omitted because pelican has no nested metadata.
+func encodePelican(entries []MetadataKeyValue) (string, error) {
+	var buf bytes.Buffer
+	for _, kv := range entries {
+		if kv.IsMapValue() {
+			continue
+		}
+		if !pelicanKeyRe.MatchString(kv.Key) {
+			return "", fmt.Errorf("invalid pelican metadata key: %q", kv.Key)
+		}
+		value := kv.Value()
+		if kv.IsListValue() {
+			value = strings.Join(kv.ListValue(), ", ")
+		}
+		lines := []string{}
+		for _, line := range strings.Split(value, "\n") {
+			// blank line ends metadata
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, strings.TrimRight(line, " \t\r"))
+			}
+		}
+		fmt.Fprintf(&buf, "%s: %s\n", strings.Title(kv.Key), strings.Join(lines, "\n    "))
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,274 @@
+package maya
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMetadata_types(t *testing.T) {
+	text := `
+title: hello
+draft: true
+weight: 1.5
+date: 2016-02-20
+midnight: 2016-02-20T00:00:00Z
+quoted: "2016-02-20"
+mixed: [a, 1, false]
+params:
+  b: 1
+  a: [x]
+`
+	metadata, err := NewMetadata(text)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		key     string
+		value   interface{}
+		literal bool
+	}{
+		{"title", "hello", false},
+		{"draft", true, true},
+		{"weight", 1.5, true},
+		{"date", time.Date(2016, 2, 20, 0, 0, 0, 0, time.UTC), true},
+		{"midnight", time.Date(2016, 2, 20, 0, 0, 0, 0, time.UTC), true},
+		{"quoted", "2016-02-20", false},
+		{"mixed", []interface{}{"a", 1, false}, false},
+		{"params", []MetadataKeyValue{
+			{Key: "b", value: 1},
+			{Key: "a", value: []interface{}{"x"}},
+		}, false},
+	}
+	if assert.Len(t, metadata.Table, len(cases)) {
+		for i, c := range cases {
+			kv := metadata.Table[i]
+			assert.Equal(t, c.key, kv.Key)
+			assert.Equal(t, c.value, kv.Interface(), c.key)
+			assert.Equal(t, c.literal, kv.IsLiteral(), c.key)
+		}
+	}
+
+	// time of midnight is not a date
+	assert.Equal(t, "2016-02-20", metadata.Table[3].Value())
+	assert.Equal(t, "2016-02-20T00:00:00Z", metadata.Table[4].Value())
+	assert.Equal(t, []string{"a", "1", "false"}, metadata.Table[6].ListValue())
+	assert.True(t, metadata.Table[7].IsMapValue())
+	assert.Equal(t, "", metadata.Table[7].Value())
+}
+
+func TestNewMetadata_notMap(t *testing.T) {
+	_, err := NewMetadata("hello")
+	assert.NotNil(t, err)
+
+	metadata, err := NewMetadata("")
+	assert.Nil(t, err)
+	assert.Equal(t, []MetadataKeyValue{}, metadata.Table)
+}
+
+func Test_encodeTOML(t *testing.T) {
+	entries := []MetadataKeyValue{
+		{Key: "draft", value: true},
+		{Key: "weight", value: 2.0},
+		{Key: "empty", value: nil},
+		{Key: "params", value: []MetadataKeyValue{
+			{Key: "social", value: []MetadataKeyValue{{Key: "twitter", value: "maya"}}},
+			{Key: "cover image", value: "a.png"},
+		}},
+		{Key: "date", value: time.Date(2016, 2, 20, 10, 20, 0, 0, time.UTC)},
+		{Key: "day", value: metadataDate{time.Date(2016, 2, 20, 0, 0, 0, 0, time.UTC)}},
+		{Key: "midnight", value: time.Date(2016, 2, 20, 0, 0, 0, 0, time.UTC)},
+		{Key: "authors", value: []interface{}{nil, "a", 1}},
+		{Key: "links", value: []interface{}{
+			[]MetadataKeyValue{{Key: "name", value: "a"}, {Key: "url", value: "b"}},
+			[]MetadataKeyValue{{Key: "name", value: "c"}},
+		}},
+		{Key: "summary", value: "a \"quoted\" \\ line\nnext\x01"},
+	}
+	expected := `draft = true
+weight = 2.0
+date = 2016-02-20T10:20:00Z
+day = 2016-02-20
+midnight = 2016-02-20T00:00:00Z
+authors = ["a", 1]
+summary = "a \"quoted\" \\ line\nnext\u0001"
+[params]
+"cover image" = "a.png"
+[params.social]
+twitter = "maya"
+[[links]]
+name = "a"
+url = "b"
+[[links]]
+name = "c"
+`
+	actual, err := encodeTOML(entries)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func Test_encodeYAML(t *testing.T) {
+	entries := []MetadataKeyValue{
+		{Key: "draft", value: false},
+		{Key: "empty", value: nil},
+		{Key: "date", value: metadataDate{time.Date(2016, 2, 20, 0, 0, 0, 0, time.UTC)}},
+		{Key: "midnight", value: time.Date(2016, 2, 20, 0, 0, 0, 0, time.UTC)},
+		{Key: "params", value: []MetadataKeyValue{
+			{Key: "z", value: []interface{}{"a", 1, metadataDate{time.Date(2016, 2, 21, 0, 0, 0, 0, time.UTC)}}},
+			{Key: "a", value: "yes"},
+			{Key: "quoted", value: "2016-02-20"},
+		}},
+		{Key: "links", value: []interface{}{
+			[]MetadataKeyValue{{Key: "name", value: "a"}, {Key: "tags", value: []interface{}{"x"}}},
+			[]interface{}{"b", "line\nnext"},
+			[]interface{}{},
+		}},
+		{Key: "empty_map", value: []MetadataKeyValue{}},
+		{Key: "summary", value: "line\n\nnext"},
+	}
+	expected := `draft: false
+empty: null
+date: 2016-02-20
+midnight: 2016-02-20T00:00:00Z
+params:
+  z:
+  - a
+  - 1
+  - 2016-02-21
+  a: "yes"
+  quoted: "2016-02-20"
+links:
+- name: a
+  tags:
+  - x
+- - b
+  - |-
+    line
+    next
+- []
+empty_map: {}
+summary: |-
+  line
+
+  next
+`
+	actual, err := encodeYAML(entries)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+
+	actual, err = encodeYAML([]MetadataKeyValue{})
+	assert.Nil(t, err)
+	assert.Equal(t, "", actual)
+}
+
+func Test_encodeJSON(t *testing.T) {
+	entries := []MetadataKeyValue{
+		{Key: "title", value: "<b> \"a\""},
+		{Key: "empty", value: nil},
+		{Key: "day", value: metadataDate{time.Date(2016, 2, 20, 0, 0, 0, 0, time.UTC)}},
+		{Key: "midnight", value: time.Date(2016, 2, 20, 0, 0, 0, 0, time.UTC)},
+		{Key: "params", value: []MetadataKeyValue{
+			{Key: "z", value: []interface{}{1.5, true}},
+			{Key: "a", value: []MetadataKeyValue{}},
+		}},
+	}
+	expected := `{
+  "title": "<b> \"a\"",
+  "empty": null,
+  "day": "2016-02-20",
+  "midnight": "2016-02-20T00:00:00Z",
+  "params": {
+    "z": [
+      1.5,
+      true
+    ],
+    "a": {}
+  }
+}
+`
+	actual, err := encodeJSON(entries)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func Test_encodePelican(t *testing.T) {
+	entries := []MetadataKeyValue{
+		{Key: "title", value: "hello"},
+		{Key: "tags", value: []interface{}{"a", 1}},
+		{Key: "params", value: []MetadataKeyValue{{Key: "a", value: "b"}}},
+		{Key: "summary", value: "line\n\nnext  "},
+	}
+	expected := `Title: hello
+Tags: a, 1
+Summary: line
+    next
+`
+	actual, err := encodePelican(entries)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+
+	_, err = encodePelican([]MetadataKeyValue{{Key: "a b", value: "c"}})
+	assert.NotNil(t, err)
+}
+
+func TestParseMetadata_toml(t *testing.T) {
+	text := `
+title = "hello"
+weight = 2
+date = 2016-02-20
+updated = 2016-02-20T10:20:00
+tags = ["a", "b"]
+[params]
+z = 1.5
+a = { y = 1, x = 2 }
+[[links]]
+name = "a"
+`
+	metadata, err := ParseMetadata(text, MetadataTOML)
+	assert.Nil(t, err)
+	assert.Equal(t, []MetadataKeyValue{
+		{Key: "title", value: "hello"},
+		{Key: "weight", value: 2},
+		{Key: "date", value: metadataDate{time.Date(2016, 2, 20, 0, 0, 0, 0, time.UTC)}},
+		{Key: "updated", value: "2016-02-20T10:20:00"},
+		{Key: "tags", value: []interface{}{"a", "b"}},
+		{Key: "params", value: []MetadataKeyValue{
+			{Key: "z", value: 1.5},
+			{Key: "a", value: []MetadataKeyValue{{Key: "y", value: 1}, {Key: "x", value: 2}}},
+		}},
+		{Key: "links", value: []interface{}{
+			[]MetadataKeyValue{{Key: "name", value: "a"}},
+		}},
+	}, metadata.Table)
+
+	_, err = ParseMetadata("title = ", MetadataTOML)
+	assert.NotNil(t, err)
+}
+
+func TestParseMetadata_json(t *testing.T) {
+	text := `{"title": "hello", "weight": 2, "ratio": 1.5, "draft": false,
+"empty": null, "tags": ["a", 1], "params": {"z": {}, "a": []}}`
+	metadata, err := ParseMetadata(text, MetadataJSON)
+	assert.Nil(t, err)
+	assert.Equal(t, []MetadataKeyValue{
+		{Key: "title", value: "hello"},
+		{Key: "weight", value: 2},
+		{Key: "ratio", value: 1.5},
+		{Key: "draft", value: false},
+		{Key: "empty", value: nil},
+		{Key: "tags", value: []interface{}{"a", 1}},
+		{Key: "params", value: []MetadataKeyValue{
+			{Key: "z", value: []MetadataKeyValue{}},
+			{Key: "a", value: []interface{}{}},
+		}},
+	}, metadata.Table)
+
+	invalid := []string{`[1]`, `{"a": 1} {}`, `{"a": }`}
+	for _, text := range invalid {
+		_, err := ParseMetadata(text, MetadataJSON)
+		assert.NotNil(t, err, text)
+	}
+
+	_, err = ParseMetadata("", "xml")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,41 @@
+package maya
+
+import "time"
+
+// Options controls how an article is rendered.
+type Options struct {
+	// CollectErrors makes rendering report every broken block
+	// instead of stopping at the first one.
+	CollectErrors bool
+	// Workers is the number of blocks evaluated concurrently.
+	// if zero, blocks are evaluated one by one in document order.
+	Workers int
+	// Timeout is the default timeout of maya:execute.
+	// if zero, commands run without timeout.
+	Timeout time.Duration
+
+	// CacheDir stores output of commands. if empty, ./cache is used.
+	CacheDir string
+	// NoCache disables reading and writing cache.
+	NoCache bool
+	// RefreshCache ignores existing cache and writes new output.
+	RefreshCache bool
+
+	// Root is the project directory. if set, files outside of it are not read.
+	Root string
+	// SearchPaths are directories where files not found next to the document
+	// are looked up. relative path is resolved from Root.
+	SearchPaths []string
+
+	// GistFetcher gets gists rendered inline. if nil, GitHub API is used.
+	GistFetcher GistFetcher
+}
+
+// environment is passed to commands when they are created.
+type environment struct {
+	Options
+	// file is the source file of the article. empty if unknown.
+	file string
+	// mode is MetadataMode of the article. commands render output native to it.
+	mode string
+}
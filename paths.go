@@ -0,0 +1,80 @@
+package maya
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dir returns absolute directory of the document.
+// if the document is unknown, process cwd is used.
+func (env *environment) dir() string {
+	dir, _ := os.Getwd()
+	if env.file != "" {
+		dir = filepath.Dir(env.file)
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return dir
+}
+
+// resolve finds the file referenced by the document.
+// relative path is looked up next to the document, then in SearchPaths.
+// if Root is set, files outside of it are refused.
+func (env *environment) resolve(name string) (string, error) {
+	candidates := []string{}
+	if filepath.IsAbs(name) {
+		candidates = append(candidates, filepath.Clean(name))
+	} else {
+		candidates = append(candidates, filepath.Join(env.dir(), name))
+		for _, dir := range env.SearchPaths {
+			if !filepath.IsAbs(dir) && env.Root != "" {
+				dir = filepath.Join(env.Root, dir)
+			}
+			if abs, err := filepath.Abs(filepath.Join(dir, name)); err == nil {
+				candidates = append(candidates, abs)
+			}
+		}
+	}
+
+	found := candidates[0]
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			found = path
+			break
+		}
+	}
+
+	if err := env.checkRoot(found); err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return found, nil
+}
+
+// checkRoot returns error if path is outside of Root.
+// symbolic links are followed to prevent escaping root.
+func (env *environment) checkRoot(path string) error {
+	if env.Root == "" {
+		return nil
+	}
+	root, err := filepath.Abs(env.Root)
+	if err != nil {
+		return err
+	}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	} else if real, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		// file does not exist. reading it fails later with better message
+		path = filepath.Join(real, filepath.Base(path))
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("outside of root %s", env.Root)
+	}
+	return nil
+}
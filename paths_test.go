@@ -0,0 +1,86 @@
+package maya
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironment_resolve(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	for _, path := range []string{"posts/a.txt", "posts/b.txt", "snippets/b.txt", "snippets/c.txt"} {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(path), 0644)
+	}
+	outside, _ := ioutil.TempFile("", "maya")
+	outside.Close()
+	defer os.Remove(outside.Name())
+
+	env := &environment{
+		Options: Options{Root: dir, SearchPaths: []string{"snippets"}},
+		file:    filepath.Join(dir, "posts", "article.md"),
+	}
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"a.txt", filepath.Join(dir, "posts", "a.txt")},
+		// next to the document is preferred
+		{"b.txt", filepath.Join(dir, "posts", "b.txt")},
+		{"c.txt", filepath.Join(dir, "snippets", "c.txt")},
+		{"../snippets/c.txt", filepath.Join(dir, "snippets", "c.txt")},
+		{filepath.Join(dir, "snippets", "c.txt"), filepath.Join(dir, "snippets", "c.txt")},
+		// not found, reading it fails later
+		{"d.txt", filepath.Join(dir, "posts", "d.txt")},
+	}
+	for _, c := range cases {
+		actual, err := env.resolve(c.name)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.expected, actual, c.name)
+	}
+
+	invalids := []string{
+		"../../etc/passwd",
+		outside.Name(),
+	}
+	for _, name := range invalids {
+		_, err := env.resolve(name)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestEnvironment_resolve_symlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink requires privilege")
+	}
+	dir, _ := ioutil.TempDir("", "maya")
+	defer os.RemoveAll(dir)
+	outside, _ := ioutil.TempFile("", "maya")
+	outside.Close()
+	defer os.Remove(outside.Name())
+
+	os.Symlink(outside.Name(), filepath.Join(dir, "link.txt"))
+	env := &environment{Options: Options{Root: dir}, file: filepath.Join(dir, "article.md")}
+	_, err := env.resolve("link.txt")
+	assert.NotNil(t, err)
+}
+
+func TestEnvironment_resolve_noRoot(t *testing.T) {
+	cwd, _ := os.Getwd()
+	env := &environment{}
+	actual, err := env.resolve("demo.py")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(cwd, "demo.py"), actual)
+
+	actual, err = env.resolve("/etc/passwd")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Clean("/etc/passwd"), actual)
+}
@@ -0,0 +1,62 @@
+package maya
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// regionMarkerRe matches `maya:begin name` and `maya:end name` in comments.
+// supported styles are #, //, --, /* */ and <!-- -->
+var regionMarkerRe = regexp.MustCompile(`^\s*(?:#|//|--|/\*|<!--)\s*maya:(begin|end)\s+([\w.-]+)\s*(?:\*/|-->)?\s*$`)
+
+// parseRegionMarker returns kind (begin/end) and name of marker line.
+func parseRegionMarker(line string) (string, string, bool) {
+	m := regionMarkerRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// regionRange returns 1-based first and last line inside of the region.
+// marker lines are not included.
+func regionRange(lines []string, name string) (int, int, error) {
+	begins := []int{}
+	ends := []int{}
+	for i, line := range lines {
+		kind, n, ok := parseRegionMarker(line)
+		if !ok || n != name {
+			continue
+		}
+		if kind == "begin" {
+			begins = append(begins, i+1)
+		} else {
+			ends = append(ends, i+1)
+		}
+	}
+
+	switch {
+	case len(begins) == 0:
+		return 0, 0, fmt.Errorf("region %s not found", name)
+	case len(begins) > 1:
+		return 0, 0, fmt.Errorf("region %s begins %d times, lines %v", name, len(begins), begins)
+	case len(ends) == 0:
+		return 0, 0, fmt.Errorf("region %s at line %d has no end marker", name, begins[0])
+	case len(ends) > 1:
+		return 0, 0, fmt.Errorf("region %s ends %d times, lines %v", name, len(ends), ends)
+	case ends[0] < begins[0]:
+		return 0, 0, fmt.Errorf("region %s ends at line %d before it begins at line %d", name, ends[0], begins[0])
+	}
+	return begins[0] + 1, ends[0] - 1, nil
+}
+
+// stripRegionMarkers removes marker lines of other regions.
+func stripRegionMarkers(lines []sourceLine) []sourceLine {
+	elems := []sourceLine{}
+	for _, line := range lines {
+		if _, _, ok := parseRegionMarker(line.text); !ok {
+			elems = append(elems, line)
+		}
+	}
+	return elems
+}
@@ -0,0 +1,79 @@
+package maya
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRegionMarker(t *testing.T) {
+	cases := []struct {
+		line string
+		kind string
+		name string
+		ok   bool
+	}{
+		{"# maya:begin setup", "begin", "setup", true},
+		{"    // maya:end setup", "end", "setup", true},
+		{"-- maya:begin query-1", "begin", "query-1", true},
+		{"/* maya:begin style */", "begin", "style", true},
+		{"<!-- maya:end body -->", "end", "body", true},
+		{"\t#maya:begin x.y", "begin", "x.y", true},
+		{"x := 1 // maya:begin setup", "", "", false},
+		{"// maya:begin", "", "", false},
+		{"// maya:start setup", "", "", false},
+	}
+	for _, c := range cases {
+		kind, name, ok := parseRegionMarker(c.line)
+		assert.Equal(t, c.ok, ok, c.line)
+		assert.Equal(t, c.kind, kind, c.line)
+		assert.Equal(t, c.name, name, c.line)
+	}
+}
+
+func TestRegionRange(t *testing.T) {
+	lines := strings.Split(strings.Join([]string{
+		"import os",
+		"# maya:begin outer",
+		"def main():",
+		"    # maya:begin inner",
+		"    print('hello')",
+		"    # maya:end inner",
+		"# maya:end outer",
+		"# maya:begin empty",
+		"# maya:end empty",
+	}, "\n"), "\n")
+
+	cases := []struct {
+		name     string
+		expected []string
+	}{
+		{"outer", []string{"def main():", "    print('hello')"}},
+		{"inner", []string{"    print('hello')"}},
+		{"empty", []string{}},
+	}
+	numbered := numberLines(lines, 1)
+	for _, c := range cases {
+		first, last, err := regionRange(lines, c.name)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.expected, lineTexts(stripRegionMarkers(numbered[first-1:last])), c.name)
+	}
+}
+
+func TestRegionRange_invalid(t *testing.T) {
+	cases := []struct {
+		lines []string
+		name  string
+	}{
+		{[]string{"// maya:begin a", "// maya:end a"}, "b"},
+		{[]string{"// maya:begin a"}, "a"},
+		{[]string{"// maya:begin a", "// maya:end a", "// maya:begin a", "// maya:end a"}, "a"},
+		{[]string{"// maya:begin a", "// maya:end a", "// maya:end a"}, "a"},
+		{[]string{"// maya:end a", "// maya:begin a"}, "a"},
+	}
+	for _, c := range cases {
+		_, _, err := regionRange(c.lines, c.name)
+		assert.NotNil(t, err, c.lines)
+	}
+}